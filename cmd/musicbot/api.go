@@ -0,0 +1,251 @@
+// api.go
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LightQuotient/discord-music-bot/internal/musicbot"
+)
+
+// maxRequestBody bounds the JSON bodies the API accepts
+const maxRequestBody = 64 << 10
+
+// apiServer exposes the player operations over HTTP for remote controls
+type apiServer struct {
+	bot    *musicbot.MusicBot
+	tokens [][]byte
+}
+
+// playerHandler handles a request for the guild named in the {id} path segment
+type playerHandler func(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer)
+
+func newAPIServer(bot *musicbot.MusicBot, tokens []string) *apiServer {
+	api := &apiServer{bot: bot}
+	for _, token := range tokens {
+		api.tokens = append(api.tokens, []byte(token))
+	}
+	return api
+}
+
+// register adds the API routes to mux
+func (api *apiServer) register(mux *http.ServeMux) {
+	mux.Handle("GET /guilds/{id}/queue", api.guild(api.getQueue))
+	mux.Handle("POST /guilds/{id}/play", api.guild(api.play))
+	mux.Handle("POST /guilds/{id}/pause", api.guild(api.pause))
+	mux.Handle("POST /guilds/{id}/resume", api.guild(api.resume))
+	mux.Handle("POST /guilds/{id}/skip", api.guild(api.skip))
+	mux.Handle("POST /guilds/{id}/seek", api.guild(api.seek))
+	mux.Handle("POST /guilds/{id}/volume", api.guild(api.volume))
+	mux.Handle("DELETE /guilds/{id}/queue/{pos}", api.guild(api.removeFromQueue))
+}
+
+// guild authenticates the request and looks up the player for {id}
+func (api *apiServer) guild(next playerHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="musicbot"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+
+		gp, err := api.bot.Guild(r.PathValue("id"))
+		if err != nil {
+			writeOpError(w, err)
+			return
+		}
+		next(w, r, gp)
+	})
+}
+
+// authorized checks the bearer token in constant time against every configured token
+func (api *apiServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	match := 0
+	for _, t := range api.tokens {
+		match |= subtle.ConstantTimeCompare([]byte(token), t)
+	}
+	return match == 1
+}
+
+func (api *apiServer) getQueue(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	writeJSON(w, http.StatusOK, gp.Status())
+}
+
+type playRequest struct {
+	Input     string `json:"input"`      // Link or search words, like the /play url option
+	ChannelID string `json:"channel_id"` // Voice channel to join; optional if the bot is already connected
+	Shuffle   bool   `json:"shuffle"`
+	Offset    int    `json:"offset"`
+	Limit     int    `json:"limit"`
+}
+
+type playResponse struct {
+	Message string              `json:"message"`
+	Added   []musicbot.SongInfo `json:"added"`
+}
+
+func (api *apiServer) play(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	var req playRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.Input = strings.TrimSpace(req.Input)
+	if req.Input == "" {
+		writeError(w, http.StatusBadRequest, "input is required")
+		return
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		writeError(w, http.StatusBadRequest, "offset and limit must not be negative")
+		return
+	}
+
+	slog.Info("API play request", "guild_id", gp.GuildID, "input", req.Input)
+	res, err := gp.Enqueue(r.Context(), req.Input, req.ChannelID, musicbot.PlaylistOptions{
+		Shuffle: req.Shuffle,
+		Offset:  req.Offset,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		if isConflict(err) {
+			writeOpError(w, err)
+		} else {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+		}
+		return
+	}
+
+	resp := playResponse{Message: res.Message()}
+	for _, song := range res.Songs {
+		resp.Added = append(resp.Added, song.Info())
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (api *apiServer) pause(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	api.apply(w, gp, gp.Pause())
+}
+
+func (api *apiServer) resume(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	api.apply(w, gp, gp.Resume())
+}
+
+func (api *apiServer) skip(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	api.apply(w, gp, gp.Skip())
+}
+
+type seekRequest struct {
+	Position string `json:"position"` // "1:23", "90", "+30" or "-15", like /seek
+}
+
+func (api *apiServer) seek(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	var req seekRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	target, err := musicbot.ParseSeekTarget(req.Position, gp.Position())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	api.apply(w, gp, gp.Seek(target))
+}
+
+type volumeRequest struct {
+	Level *int `json:"level"` // Percent, 0-200
+}
+
+func (api *apiServer) volume(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	var req volumeRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Level == nil {
+		writeError(w, http.StatusBadRequest, "level is required")
+		return
+	}
+	api.apply(w, gp, gp.SetVolume(*req.Level))
+}
+
+func (api *apiServer) removeFromQueue(w http.ResponseWriter, r *http.Request, gp *musicbot.GuildPlayer) {
+	pos, err := strconv.Atoi(r.PathValue("pos"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "pos must be a 1-based queue position")
+		return
+	}
+	if _, err := gp.RemoveFromQueue(pos, pos); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, gp.Status())
+}
+
+// apply answers with the player's new status, or the operation's error
+func (api *apiServer) apply(w http.ResponseWriter, gp *musicbot.GuildPlayer, err error) {
+	if err != nil {
+		writeOpError(w, err)
+		return
+	}
+	gp.RefreshEmbed()
+	writeJSON(w, http.StatusOK, gp.Status())
+}
+
+// isConflict reports whether err means the player is in the wrong state for the request
+func isConflict(err error) bool {
+	for _, target := range []error{
+		musicbot.ErrNothingPlaying,
+		musicbot.ErrAlreadyPaused,
+		musicbot.ErrNotPaused,
+		musicbot.ErrNotInVoice,
+		musicbot.ErrQueueFull,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOpError maps a player operation error to an HTTP status
+func writeOpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, musicbot.ErrUnknownGuild):
+		writeError(w, http.StatusNotFound, err.Error())
+	case isConflict(err):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// readJSON decodes the request body into v, answering 400 itself when it can't
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Error writing API response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
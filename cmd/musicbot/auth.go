@@ -0,0 +1,296 @@
+// auth.go
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LightQuotient/discord-music-bot/internal/musicbot"
+)
+
+const (
+	sessionCookie    = "musicbot_session"
+	oauthStateCookie = "musicbot_oauth_state"
+	discordAPI       = "https://discord.com/api/v10"
+	discordAuthorize = "https://discord.com/oauth2/authorize"
+)
+
+// dashboardSession is a signed-in dashboard user
+type dashboardSession struct {
+	UserID   string
+	Username string
+	Guilds   map[string]bool // Guilds the user is a member of; nil means any (dev login)
+	Expires  time.Time
+}
+
+// memberOf reports whether the user may open guildID's dashboard
+func (sess *dashboardSession) memberOf(guildID string) bool {
+	return sess.Guilds == nil || sess.Guilds[guildID]
+}
+
+// sessionStore keeps dashboard sessions in memory; a restart signs everyone out
+type sessionStore struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	sessions map[string]*dashboardSession
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{ttl: ttl, sessions: make(map[string]*dashboardSession)}
+}
+
+// create stores sess under a new random ID and sets the session cookie
+func (store *sessionStore) create(w http.ResponseWriter, r *http.Request, sess *dashboardSession) {
+	id := randomToken()
+	sess.Expires = time.Now().Add(store.ttl)
+
+	store.mu.Lock()
+	// Drop expired sessions while we're here so the map can't grow forever
+	for k, s := range store.sessions {
+		if time.Now().After(s.Expires) {
+			delete(store.sessions, k)
+		}
+	}
+	store.sessions[id] = sess
+	store.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  sess.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// get returns the session for the request's cookie, or nil when signed out
+func (store *sessionStore) get(r *http.Request) *dashboardSession {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	sess, ok := store.sessions[cookie.Value]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.Expires) {
+		delete(store.sessions, cookie.Value)
+		return nil
+	}
+	return sess
+}
+
+func (store *sessionStore) destroy(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		store.mu.Lock()
+		delete(store.sessions, cookie.Value)
+		store.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails if the OS has no entropy source at all
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// authHandler signs users in with Discord OAuth2, or with the dev login stand-in
+type authHandler struct {
+	cfg      musicbot.DashboardConfig
+	sessions *sessionStore
+	client   *http.Client
+}
+
+func newAuthHandler(cfg musicbot.DashboardConfig, sessions *sessionStore) *authHandler {
+	return &authHandler{
+		cfg:      cfg,
+		sessions: sessions,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (auth *authHandler) register(mux *http.ServeMux) {
+	if auth.cfg.ClientID != "" {
+		mux.HandleFunc("GET /auth/login", auth.login)
+		mux.HandleFunc("GET /auth/callback", auth.callback)
+	}
+	if auth.cfg.DevLogin {
+		slog.Warn("Dashboard dev login is enabled; anyone can sign in as any user")
+		mux.HandleFunc("GET /auth/dev", auth.devLogin)
+	}
+	mux.HandleFunc("POST /auth/logout", auth.logout)
+}
+
+func (auth *authHandler) redirectURL() string {
+	return strings.TrimSuffix(auth.cfg.BaseURL, "/") + "/auth/callback"
+}
+
+// login sends the browser to Discord's consent screen
+func (auth *authHandler) login(w http.ResponseWriter, r *http.Request) {
+	state := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {auth.cfg.ClientID},
+		"scope":         {"identify guilds"},
+		"redirect_uri":  {auth.redirectURL()},
+		"state":         {state},
+		"prompt":        {"none"},
+	}
+	http.Redirect(w, r, discordAuthorize+"?"+q.Encode(), http.StatusFound)
+}
+
+// callback exchanges the authorization code and signs the user in
+func (auth *authHandler) callback(w http.ResponseWriter, r *http.Request) {
+	stateCookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		http.Error(w, "Login expired or was tampered with, please try again.", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/auth/", MaxAge: -1})
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Discord did not return an authorization code.", http.StatusBadRequest)
+		return
+	}
+
+	token, err := auth.exchangeCode(r.Context(), code)
+	if err != nil {
+		slog.Warn("OAuth2 code exchange failed", "err", err)
+		http.Error(w, "Could not sign in with Discord.", http.StatusBadGateway)
+		return
+	}
+
+	var user struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+	}
+	if err := auth.discordGET(r.Context(), token, "/users/@me", &user); err != nil {
+		slog.Warn("Fetching Discord user failed", "err", err)
+		http.Error(w, "Could not sign in with Discord.", http.StatusBadGateway)
+		return
+	}
+	var guilds []struct {
+		ID string `json:"id"`
+	}
+	if err := auth.discordGET(r.Context(), token, "/users/@me/guilds", &guilds); err != nil {
+		slog.Warn("Fetching Discord guilds failed", "err", err)
+		http.Error(w, "Could not sign in with Discord.", http.StatusBadGateway)
+		return
+	}
+
+	sess := &dashboardSession{UserID: user.ID, Username: user.GlobalName, Guilds: make(map[string]bool)}
+	if sess.Username == "" {
+		sess.Username = user.Username
+	}
+	for _, g := range guilds {
+		sess.Guilds[g.ID] = true
+	}
+	auth.sessions.create(w, r, sess)
+	slog.Info("Dashboard login", "user_id", sess.UserID, "username", sess.Username)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// exchangeCode trades an authorization code for an access token
+func (auth *authHandler) exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {auth.redirectURL()},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discordAPI+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(auth.cfg.ClientID, auth.cfg.ClientSecret)
+
+	resp, err := auth.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned no access token")
+	}
+	return body.AccessToken, nil
+}
+
+// discordGET calls the Discord API on behalf of the user
+func (auth *authHandler) discordGET(ctx context.Context, token, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discordAPI+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := auth.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// devLogin signs in as ?user_id=...&name=... without Discord, for local testing
+func (auth *authHandler) devLogin(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "dev-" + userID
+	}
+
+	auth.sessions.create(w, r, &dashboardSession{UserID: userID, Username: name})
+	slog.Info("Dashboard dev login", "user_id", userID, "username", name)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (auth *authHandler) logout(w http.ResponseWriter, r *http.Request) {
+	auth.sessions.destroy(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
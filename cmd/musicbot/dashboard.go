@@ -0,0 +1,261 @@
+// dashboard.go
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/LightQuotient/discord-music-bot/internal/musicbot"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+const (
+	// dashboardTick pushes progress while a song plays even when nothing else changes
+	dashboardTick = time.Second
+	wsWriteWait   = 10 * time.Second
+	wsPongWait    = 60 * time.Second
+	wsPingPeriod  = wsPongWait / 2
+)
+
+var (
+	errNotListening  = errors.New("join the bot's voice channel to control playback")
+	errNotAllowed    = errors.New("only DJs, server admins or whoever requested the song can do that while others are listening")
+	errUnknownAction = errors.New("unknown action")
+)
+
+// upgrader keeps gorilla's default same-origin check, so other sites can't
+// open a socket with a signed-in user's cookie
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// dashboard serves the single-page web UI and its live WebSocket feed
+type dashboard struct {
+	bot      *musicbot.MusicBot
+	sessions *sessionStore
+}
+
+func (d *dashboard) register(mux *http.ServeMux) {
+	static, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		fatal("Embedded dashboard files missing", "err", err)
+	}
+	mux.Handle("GET /{$}", http.FileServerFS(static))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /dashboard/me", d.me)
+	mux.HandleFunc("GET /dashboard/guilds/{id}/ws", d.socket)
+}
+
+type meResponse struct {
+	UserID   string                  `json:"user_id"`
+	Username string                  `json:"username"`
+	Guilds   []musicbot.GuildSummary `json:"guilds"`
+}
+
+// me describes the signed-in user and the guilds they can open, or 401 when signed out
+func (d *dashboard) me(w http.ResponseWriter, r *http.Request) {
+	sess := d.sessions.get(r)
+	if sess == nil {
+		cfg := d.bot.Config.Dashboard
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "not signed in",
+			"login": map[string]bool{"discord": cfg.ClientID != "", "dev": cfg.DevLogin},
+		})
+		return
+	}
+
+	resp := meResponse{UserID: sess.UserID, Username: sess.Username, Guilds: []musicbot.GuildSummary{}}
+	for _, g := range d.bot.Guilds() {
+		if sess.memberOf(g.ID) {
+			resp.Guilds = append(resp.Guilds, g)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// wsStatus is pushed to the browser whenever the player changes
+type wsStatus struct {
+	Type       string                `json:"type"` // "status"
+	Status     musicbot.PlayerStatus `json:"status"`
+	CanControl bool                  `json:"can_control"`
+}
+
+// wsCommand is a control action sent by the browser
+type wsCommand struct {
+	Action   string `json:"action"` // pause, resume, skip, seek, volume, move or remove
+	Position string `json:"position,omitempty"`
+	Level    int    `json:"level,omitempty"`
+	From     int    `json:"from,omitempty"`
+	To       int    `json:"to,omitempty"`
+}
+
+type wsError struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
+}
+
+// socket streams a guild's status and accepts control commands from users in its voice channel
+func (d *dashboard) socket(w http.ResponseWriter, r *http.Request) {
+	sess := d.sessions.get(r)
+	if sess == nil {
+		writeError(w, http.StatusUnauthorized, "not signed in")
+		return
+	}
+	guildID := r.PathValue("id")
+	if !sess.memberOf(guildID) {
+		writeError(w, http.StatusForbidden, "you are not a member of that server")
+		return
+	}
+	gp, err := d.bot.Guild(guildID)
+	if err != nil {
+		writeOpError(w, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already answered the request
+		slog.Warn("WebSocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	commands := make(chan wsCommand)
+	go d.readCommands(ctx, cancel, conn, commands)
+
+	changes, stop := gp.Watch()
+	defer stop()
+	ticker := time.NewTicker(dashboardTick)
+	defer ticker.Stop()
+	pings := time.NewTicker(wsPingPeriod)
+	defer pings.Stop()
+
+	send := func(v any) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(v); err != nil {
+			slog.Debug("Dashboard WebSocket write failed", "guild_id", guildID, "user_id", sess.UserID, "err", err)
+			return false
+		}
+		return true
+	}
+	sendStatus := func() bool {
+		return send(wsStatus{Type: "status", Status: gp.Status(), CanControl: gp.InVoiceChannel(sess.UserID)})
+	}
+
+	if !sendStatus() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		case <-pings.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+			continue
+		case cmd := <-commands:
+			if err := d.apply(gp, sess, cmd); err != nil {
+				if !send(wsError{Type: "error", Error: err.Error()}) {
+					return
+				}
+				continue
+			}
+			gp.RefreshEmbed()
+		}
+		if !sendStatus() {
+			return
+		}
+	}
+}
+
+// readCommands forwards the browser's commands until the socket closes
+func (d *dashboard) readCommands(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, commands chan<- wsCommand) {
+	defer cancel()
+
+	conn.SetReadLimit(maxRequestBody)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var cmd wsCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Debug("Dashboard WebSocket closed", "err", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		select {
+		case commands <- cmd:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply runs a control command after checking the user is listening along and
+// may control the songs it affects
+func (d *dashboard) apply(gp *musicbot.GuildPlayer, sess *dashboardSession, cmd wsCommand) error {
+	if !gp.InVoiceChannel(sess.UserID) {
+		return errNotListening
+	}
+	var owners []string
+	switch cmd.Action {
+	case "pause", "resume", "skip", "seek":
+		owners = gp.CurrentOwners()
+	case "remove":
+		owners = gp.QueueOwners(cmd.From, cmd.From)
+	}
+	if !gp.MayControl(sess.UserID, owners...) {
+		if cmd.Action == "skip" {
+			_, err := gp.VoteSkip(sess.UserID)
+			return err
+		}
+		return errNotAllowed
+	}
+	slog.Info("Dashboard command", "action", cmd.Action, "guild_id", gp.GuildID, "user_id", sess.UserID)
+
+	switch cmd.Action {
+	case "pause":
+		return gp.Pause()
+	case "resume":
+		return gp.Resume()
+	case "skip":
+		return gp.Skip()
+	case "seek":
+		target, err := musicbot.ParseSeekTarget(cmd.Position, gp.Position())
+		if err != nil {
+			return err
+		}
+		return gp.Seek(target)
+	case "volume":
+		return gp.SetVolume(cmd.Level)
+	case "move":
+		_, err := gp.MoveInQueue(cmd.From, cmd.To)
+		return err
+	case "remove":
+		_, err := gp.RemoveFromQueue(cmd.From, cmd.From)
+		return err
+	default:
+		return errUnknownAction
+	}
+}
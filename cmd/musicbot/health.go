@@ -0,0 +1,27 @@
+// health.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/LightQuotient/discord-music-bot/internal/musicbot"
+)
+
+// registerHealth adds the unauthenticated probe endpoints: /healthz fails when
+// a restart would help, /readyz when the bot can't serve commands
+func registerHealth(mux *http.ServeMux, bot *musicbot.MusicBot) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, bot.Liveness())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, bot.Readiness(r.Context()))
+	})
+}
+
+func writeHealth(w http.ResponseWriter, report musicbot.HealthReport) {
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
@@ -2,34 +2,123 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/LightQuotient/discord-music-bot/internal/musicbot"
 )
 
+// shutdownTimeout bounds how long a SIGTERM waits for players to clean up
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	token := os.Getenv("BOT_TOKEN")
-	if token == "" {
-		log.Fatalln("BOT_TOKEN not set.")
+	cfg, err := musicbot.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if err := musicbot.SetupLogging(os.Stderr, cfg.Log); err != nil {
+		fatal("Invalid log configuration", "err", err)
 	}
 
-	session, err := discordgo.New("Bot " + token)
+	session, err := discordgo.New("Bot " + cfg.Token)
 	if err != nil {
-		log.Fatalf("Error creating Discord session: %v", err)
+		fatal("Error creating Discord session", "err", err)
 	}
 
-	bot := musicbot.NewMusicBot(session)
+	bot, err := musicbot.NewMusicBot(session, cfg)
+	if err != nil {
+		fatal("Error creating Music Bot", "err", err)
+	}
 	bot.Start()
+	srv := startHTTPServer(cfg, bot)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
+	// Stop playback and save queues first, so a slow HTTP request can't eat into
+	// their deadline; cancelling the bot also ends play requests still resolving
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown did not finish cleanly", "err", err)
+	}
+	if srv != nil {
+		httpCtx, httpCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer httpCancel()
+		if err := srv.Shutdown(httpCtx); err != nil {
+			slog.Warn("HTTP server did not shut down cleanly", "err", err)
+		}
+	}
 	session.Close()
-	log.Println("Bot stopped.")
+	slog.Info("Bot stopped")
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// registerCovers serves the library's cached cover art, which Discord fetches
+// for the thumbnails of library songs
+func registerCovers(mux *http.ServeMux, lib *musicbot.Library) {
+	mux.HandleFunc("GET /library/covers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		path, ok := lib.CoverPath(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, path)
+	})
+}
+
+// startHTTPServer serves /metrics and the health probes, library covers, and the API and
+// dashboard when configured, on cfg.HTTPAddr. It returns nil when http_addr is empty.
+func startHTTPServer(cfg *musicbot.Config, bot *musicbot.MusicBot) *http.Server {
+	if cfg.HTTPAddr == "" {
+		slog.Info("HTTP server disabled: no http_addr configured")
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	registerHealth(mux, bot)
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := bot.WriteMetrics(w); err != nil {
+			slog.Warn("Error writing metrics", "err", err)
+		}
+	})
+	if bot.Library != nil {
+		registerCovers(mux, bot.Library)
+	}
+	if len(cfg.APITokens) > 0 {
+		newAPIServer(bot, cfg.APITokens).register(mux)
+	}
+	if cfg.Dashboard.Enabled() {
+		sessions := newSessionStore(cfg.Dashboard.SessionTTL.Duration)
+		newAuthHandler(cfg.Dashboard, sessions).register(mux)
+		(&dashboard{bot: bot, sessions: sessions}).register(mux)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		slog.Info("HTTP server listening", "addr", cfg.HTTPAddr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("HTTP server failed", "err", err)
+		}
+	}()
+	return srv
 }
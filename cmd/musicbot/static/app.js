@@ -0,0 +1,165 @@
+// app.js drives the dashboard: sign-in, guild picker and the live player view
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+let socket = null;
+let status = null;
+let canControl = false;
+let dragFrom = 0;
+
+function formatTime(seconds) {
+  seconds = Math.floor(seconds);
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = String(seconds % 60).padStart(2, "0");
+  return h > 0 ? `${h}:${String(m).padStart(2, "0")}:${s}` : `${m}:${s}`;
+}
+
+function showMessage(text) {
+  const el = $("message");
+  el.textContent = text;
+  el.hidden = false;
+  clearTimeout(showMessage.timer);
+  showMessage.timer = setTimeout(() => { el.hidden = true; }, 5000);
+}
+
+function send(command) {
+  if (socket && socket.readyState === WebSocket.OPEN) {
+    socket.send(JSON.stringify(command));
+  }
+}
+
+function render() {
+  const current = status.current;
+  $("title").textContent = current ? current.name : "Nothing playing";
+  $("uploader").textContent = current && current.uploader ? current.uploader : "";
+  $("thumbnail").hidden = !current;
+  if (current) {
+    $("thumbnail").src = current.thumbnail;
+  }
+
+  if (current && current.duration > 0) {
+    const pct = Math.min(100, (status.position / current.duration) * 100);
+    $("progress-bar").style.width = `${pct}%`;
+    $("time").textContent = `${formatTime(status.position)} / ${formatTime(current.duration)}`;
+  } else {
+    $("progress-bar").style.width = current ? "100%" : "0";
+    $("time").textContent = current ? `${formatTime(status.position)} (live)` : "";
+  }
+
+  $("pause").textContent = status.paused ? "Resume" : "Pause";
+  $("pause").disabled = !canControl || !current;
+  $("skip").disabled = !canControl || !current;
+  $("volume").disabled = !canControl;
+  if (document.activeElement !== $("volume")) {
+    $("volume").value = status.volume;
+  }
+  $("loop").textContent = `Loop: ${status.loop}`;
+  $("listen-hint").hidden = canControl;
+
+  renderQueue();
+}
+
+function renderQueue() {
+  const list = $("queue");
+  list.replaceChildren();
+  status.queue.forEach((song, i) => {
+    const pos = i + 1;
+    const item = document.createElement("li");
+    const label = document.createElement("span");
+    label.textContent = song.duration > 0 ? `${song.name} (${formatTime(song.duration)})` : song.name;
+    item.append(label);
+
+    if (canControl) {
+      item.draggable = true;
+      item.addEventListener("dragstart", () => { dragFrom = pos; });
+      item.addEventListener("dragover", (e) => {
+        e.preventDefault();
+        item.classList.add("drop-target");
+      });
+      item.addEventListener("dragleave", () => item.classList.remove("drop-target"));
+      item.addEventListener("drop", (e) => {
+        e.preventDefault();
+        item.classList.remove("drop-target");
+        if (dragFrom && dragFrom !== pos) {
+          send({ action: "move", from: dragFrom, to: pos });
+        }
+        dragFrom = 0;
+      });
+
+      const remove = document.createElement("button");
+      remove.textContent = "✕";
+      remove.title = "Remove from queue";
+      remove.addEventListener("click", () => send({ action: "remove", from: pos }));
+      item.append(remove);
+    }
+    list.append(item);
+  });
+}
+
+function connect(guildID) {
+  if (socket) {
+    socket.onclose = null;
+    socket.close();
+  }
+  const scheme = location.protocol === "https:" ? "wss" : "ws";
+  socket = new WebSocket(`${scheme}://${location.host}/dashboard/guilds/${guildID}/ws`);
+  socket.onmessage = (event) => {
+    const msg = JSON.parse(event.data);
+    if (msg.type === "status") {
+      status = msg.status;
+      canControl = msg.can_control;
+      render();
+    } else if (msg.type === "error") {
+      showMessage(msg.error);
+    }
+  };
+  socket.onclose = () => {
+    // Reconnect after a short pause, e.g. when the bot restarts
+    setTimeout(() => connect(guildID), 3000);
+  };
+  localStorage.setItem("guild", guildID);
+}
+
+async function init() {
+  const resp = await fetch("/dashboard/me");
+  const me = await resp.json();
+
+  if (resp.status === 401) {
+    $("login").hidden = false;
+    $("discord-login").hidden = !me.login.discord;
+    $("dev-login").hidden = !me.login.dev;
+    return;
+  }
+
+  $("account").hidden = false;
+  $("username").textContent = me.username;
+  const picker = $("guilds");
+  for (const g of me.guilds) {
+    picker.append(new Option(g.name, g.id));
+  }
+  if (me.guilds.length === 0) {
+    $("login").hidden = false;
+    $("login").querySelector("p").textContent = "The bot isn't in any of your servers yet.";
+    return;
+  }
+
+  const saved = localStorage.getItem("guild");
+  if (me.guilds.some((g) => g.id === saved)) {
+    picker.value = saved;
+  }
+  picker.addEventListener("change", () => connect(picker.value));
+  $("player").hidden = false;
+  connect(picker.value);
+}
+
+$("pause").addEventListener("click", () => send({ action: status.paused ? "resume" : "pause" }));
+$("skip").addEventListener("click", () => send({ action: "skip" }));
+$("volume").addEventListener("change", (e) => send({ action: "volume", level: Number(e.target.value) }));
+$("logout").addEventListener("click", async () => {
+  await fetch("/auth/logout", { method: "POST" });
+  location.reload();
+});
+
+init();
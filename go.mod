@@ -4,10 +4,12 @@ go 1.23.4
 
 require github.com/bwmarrin/discordgo v0.28.1
 
-require layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
+require (
+	github.com/gorilla/websocket v1.5.3
+	layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
+)
 
 require (
-	github.com/gorilla/websocket v1.5.3 // indirect
 	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
@@ -2,51 +2,107 @@
 package musicbot
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os/exec"
+	"log/slog"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-// MusicBot is your main bot struct with all necessary fields
+// MusicBot is your main bot struct, holding one GuildPlayer per guild
 type MusicBot struct {
-	Session              *discordgo.Session
-	VoiceConn            *discordgo.VoiceConnection
-	Queue                []*Song
-	QueueMutex           sync.Mutex
-	CurrentlyPlaying     bool
-	EmbedInitialized     bool
-	PlaybackMutex        sync.Mutex
-	CurrentSong          *Song
-	CurrentSongMessageID string // Add this field to store the embed message ID
-	CurrentSongChannelID string // Add this field to store the channel ID
-	PauseState           struct {
-		Paused        bool
-		Mutex         sync.Mutex
-		Pos           float64
-		TotalPlayTime float64
-		SkipReq       bool
-		Cmd           *exec.Cmd
+	Session           *discordgo.Session
+	Config            *Config
+	Settings          *SettingsStore    // Per-guild overrides edited with /settings
+	MaxPlaylistTracks int               // Cap on entries enqueued from one playlist URL
+	Resolvers         *ResolverRegistry // Turns /play input into songs
+	Library           *Library          // Local music library, nil when none is configured
+	State             *StateStore       // Persists queues across restarts, nil to disable
+	commandsReady     atomic.Bool       // Set once the slash commands are registered
+	players           map[string]*GuildPlayer
+	playersMu         sync.Mutex
+	ctx               context.Context // Cancelled by Shutdown
+	cancel            context.CancelFunc
+}
+
+// NewMusicBot constructs the MusicBot and the stores and backends cfg asks for
+func NewMusicBot(session *discordgo.Session, cfg *Config) (*MusicBot, error) {
+	resolvers, err := NewResolverRegistry(cfg.Resolvers, ResolverOptions{
+		MusicDir:    cfg.MusicDir,
+		AudioFormat: cfg.AudioFormat,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid resolvers: %v", err)
 	}
+
+	settings, err := NewSettingsStore(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load guild settings: %v", err)
+	}
+
+	var library *Library
+	if cfg.MusicDir != "" {
+		library, err = NewLibrary(cfg.MusicDir, cfg.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid music directory: %v", err)
+		}
+		if cfg.HTTPAddr != "" {
+			// Discord only shows thumbnails it can fetch, so covers link to the HTTP server
+			library.coverURL = strings.TrimSuffix(cfg.Dashboard.BaseURL, "/") + "/library/covers/"
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MusicBot{
+		Session:           session,
+		Config:            cfg,
+		Settings:          settings,
+		MaxPlaylistTracks: cfg.MaxPlaylistTracks,
+		Resolvers:         resolvers,
+		Library:           library,
+		State:             NewStateStore(cfg.DataDir),
+		players:           make(map[string]*GuildPlayer),
+		ctx:               ctx,
+		cancel:            cancel,
+	}, nil
 }
 
-// NewMusicBot constructs the MusicBot and initializes values
-func NewMusicBot(session *discordgo.Session) *MusicBot {
-	bot := &MusicBot{
-		Session: session,
-		Queue:   make([]*Song, 0),
+// player returns the GuildPlayer for guildID, creating it on first use
+func (bot *MusicBot) player(guildID string) *GuildPlayer {
+	bot.playersMu.Lock()
+	defer bot.playersMu.Unlock()
+
+	gp, ok := bot.players[guildID]
+	if !ok {
+		gp = newGuildPlayer(bot, guildID)
+		bot.players[guildID] = gp
 	}
+	return gp
+}
 
-	bot.PauseState.Paused = false
-	bot.PauseState.Pos = 0
-	bot.CurrentlyPlaying = false
-	bot.CurrentSong = nil
-	bot.PauseState.Cmd = nil
-	bot.PauseState.SkipReq = false
+// lookupPlayer returns the GuildPlayer for guildID if one was created
+func (bot *MusicBot) lookupPlayer(guildID string) (*GuildPlayer, bool) {
+	bot.playersMu.Lock()
+	defer bot.playersMu.Unlock()
+	gp, ok := bot.players[guildID]
+	return gp, ok
+}
+
+// allPlayers snapshots the players created so far
+func (bot *MusicBot) allPlayers() []*GuildPlayer {
+	bot.playersMu.Lock()
+	defer bot.playersMu.Unlock()
 
-	return bot
+	players := make([]*GuildPlayer, 0, len(bot.players))
+	for _, gp := range bot.players {
+		players = append(players, gp)
+	}
+	return players
 }
 
 // handleMessages looks for bot commands (!play, !stop, etc.) and routes them
@@ -55,288 +111,320 @@ func (bot *MusicBot) handleInteraction(s *discordgo.Session, i *discordgo.Intera
 	if i.Type == discordgo.InteractionApplicationCommand {
 		// Handle slash commands
 		bot.handleApplicationCommand(s, i)
+	} else if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		bot.handleAutocomplete(s, i)
 	} else if i.Type == discordgo.InteractionMessageComponent {
 		// Handle button interactions
-		bot.handleComponentInteraction(s, i)
+		if i.GuildID == "" {
+			return
+		}
+		bot.player(i.GuildID).handleComponentInteraction(s, i)
 	} else {
-		log.Printf("Unhandled interaction type: %v", i.Type)
+		slog.Warn("Unhandled interaction type", "type", i.Type, "guild_id", i.GuildID)
 	}
 }
 
 func (bot *MusicBot) handleApplicationCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	switch i.ApplicationCommandData().Name {
+	// Every command operates on a guild's player, so refuse them in DMs
+	if i.GuildID == "" {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Music commands can only be used in a server.",
+			},
+		})
+		if err != nil {
+			metrics.discordAPIErrors.inc(callInteractionRespond)
+			slog.Warn("Error responding to DM command", "interaction_id", i.ID, "err", err)
+		}
+		metrics.slashCommands.inc(i.ApplicationCommandData().Name, "rejected")
+		return
+	}
+
+	gp := bot.player(i.GuildID)
+	channelID := i.ChannelID
+	gp.noticeChannelID.Store(&channelID)
+	name := i.ApplicationCommandData().Name
+	gp.interactionLog(i).Info("Slash command", "command", name)
+
+	var handler func(*discordgo.Session, *discordgo.InteractionCreate) error
+	async := false // Handlers that call yt-dlp or scan files run off the event goroutine
+	switch name {
 	case "play":
-		url := i.ApplicationCommandData().Options[0].StringValue()
-		go bot.handlePlayCommandSlash(s, i, url)
+		handler, async = gp.handlePlayCommandSlash, true
 	case "queue":
-		bot.listQueueSlash(s, i)
+		handler = gp.queueSlash
 	case "stop":
-		bot.stopSlash(s, i)
+		handler = gp.stopSlash
+	case "join":
+		handler, async = gp.joinSlash, true
+	case "leave":
+		handler = gp.leaveSlash
+	case "move":
+		handler, async = gp.moveSlash, true
 	case "pause":
-		bot.pauseSlash(s, i)
+		handler = gp.pauseSlash
 	case "resume":
-		bot.resumeSlash(s, i)
+		handler = gp.resumeSlash
 	case "next":
-		bot.nextSlash(s, i)
+		handler = gp.nextSlash
 	case "nowplaying":
-		bot.nowPlayingSlash(s, i)
+		handler = gp.nowPlayingSlash
 	case "restart":
-		bot.restartSlash(s, i)
+		handler = gp.restartSlash
+	case "seek":
+		handler = gp.seekSlash
+	case "forward":
+		handler = gp.forwardSlash
+	case "rewind":
+		handler = gp.rewindSlash
+	case "loop":
+		handler = gp.loopSlash
+	case "volume":
+		handler = gp.volumeSlash
+	case "library":
+		handler, async = gp.librarySlash, true
+	case "settings":
+		handler = gp.settingsSlash
 	default:
-		log.Printf("Unknown slash command: %v", i.ApplicationCommandData().Name)
+		gp.interactionLog(i).Warn("Unknown slash command", "command", name)
+		metrics.slashCommands.inc(name, "unknown")
+		return
+	}
+
+	if control, owners := gp.commandOwners(name, i); control && !gp.authorize(s, i, owners) {
+		gp.interactionLog(i).Info("Control command denied", "command", name)
+		metrics.slashCommands.inc(name, "denied")
+		return
+	}
+
+	if async {
+		go runSlash(name, func() error { return handler(s, i) })
+	} else {
+		runSlash(name, func() error { return handler(s, i) })
 	}
 }
 
+// runSlash runs a command handler and counts it once it returns. Handlers tell
+// the user about a failure themselves and return it to be counted as "error".
+func runSlash(name string, handler func() error) {
+	if err := handler(); err != nil {
+		metrics.slashCommands.inc(name, "error")
+		return
+	}
+	metrics.slashCommands.inc(name, "ok")
+}
+
+// handleVoiceStateUpdate lets the guild's player react to people joining and leaving voice
+func (bot *MusicBot) handleVoiceStateUpdate(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	gp, ok := bot.lookupPlayer(vsu.GuildID)
+	if !ok {
+		return
+	}
+	if s.State.User != nil && vsu.UserID == s.State.User.ID && vsu.BeforeUpdate != nil &&
+		vsu.ChannelID != "" && vsu.ChannelID != vsu.BeforeUpdate.ChannelID {
+		// discordgo follows the move itself; playback reconnects if audio stops flowing
+		gp.log.Info("Moved to another voice channel", "from", vsu.BeforeUpdate.ChannelID, "channel_id", vsu.ChannelID)
+	}
+	gp.dropSkipVote(s, vsu)
+	gp.checkListeners()
+	gp.checkIdle()
+}
+
 // Update Start to Register Interaction Handler
 func (bot *MusicBot) Start() {
-	log.Println("Starting Music Bot...")
+	slog.Info("Starting Music Bot")
 
 	if err := bot.Session.Open(); err != nil {
-		log.Fatalf("Failed to open Discord session: %v", err)
+		slog.Error("Failed to open Discord session", "err", err)
+		os.Exit(1)
 	}
 
 	if err := bot.registerSlashCommands(bot.Session); err != nil {
-		log.Fatalf("Failed to register slash commands: %v", err)
+		slog.Error("Failed to register slash commands", "err", err)
+		os.Exit(1)
 	}
 
 	bot.Session.AddHandler(bot.handleInteraction)
-	bot.Session.AddHandler(bot.handleComponentInteraction)
-	log.Println("Music Bot is now running!")
-}
+	bot.Session.AddHandler(bot.handleVoiceStateUpdate)
 
-// stop clears the queue, kills ffmpeg, and disconnects from voice
-func (bot *MusicBot) stopSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	log.Println("stopSlash command called")
-
-	// Clear the queue
-	bot.QueueMutex.Lock()
-	bot.Queue = nil
-	bot.QueueMutex.Unlock()
-
-	// Kill the ffmpeg process if it's running
-	bot.PauseState.Mutex.Lock()
-	if bot.PauseState.Cmd != nil {
-		log.Println("Stopping FFmpeg process...")
-		_ = bot.PauseState.Cmd.Process.Kill()
-		bot.PauseState.Cmd = nil
+	if bot.State != nil {
+		bot.restoreState()
+		go bot.saveStateLoop()
 	}
-	bot.PauseState.Mutex.Unlock()
-
-	// Disconnect from the voice channel
-	if bot.VoiceConn != nil {
-		log.Println("Disconnecting from the voice channel...")
-		bot.VoiceConn.Disconnect()
-		bot.VoiceConn = nil
-	}
-
-	// Delete the current embed message
-	if bot.CurrentSongMessageID != "" && bot.CurrentSongChannelID != "" {
-		log.Println("Deleting current song embed...")
-		err := s.ChannelMessageDelete(bot.CurrentSongChannelID, bot.CurrentSongMessageID)
-		if err != nil {
-			log.Printf("Failed to delete embed message: %v", err)
-		}
-		bot.CurrentSongMessageID = "" // Clear after deletion attempt
-		bot.CurrentSongChannelID = ""
+	if bot.Library != nil {
+		go func() {
+			if err := bot.Library.Scan(context.Background()); err != nil {
+				slog.Error("Library scan failed", "err", err)
+			}
+		}()
 	}
+	slog.Info("Music Bot is now running")
+}
 
-	// Reset playback state
-	bot.PlaybackMutex.Lock()
-	bot.CurrentlyPlaying = false
-	bot.CurrentSong = nil
-	bot.PlaybackMutex.Unlock()
-
-	// Send response to the slash command
+// respondMessage answers an interaction with a plain text message
+func respondMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Playback stopped, queue cleared, and disconnected from the voice channel.",
+			Content: content,
 		},
 	})
 	if err != nil {
-		log.Printf("Error responding to stopSlash: %v", err)
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		slog.Warn("Error responding to interaction", "guild_id", i.GuildID, "interaction_id", i.ID, "err", err)
 	}
 }
 
-// pause toggles the paused state
-func (bot *MusicBot) pauseSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	bot.PauseState.Mutex.Lock()
-	defer bot.PauseState.Mutex.Unlock()
-
-	if bot.PauseState.Paused {
-		// Respond to the slash command indicating playback is already paused
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Playback is already paused.",
-			},
-		})
-		if err != nil {
-			log.Printf("Error responding to pauseSlash: %v", err)
-		}
-		return
-	}
-
-	// Set Paused to true
-	bot.PauseState.Paused = true
-
-	log.Println("Playback paused (audio is still being read, but not sent).")
-
-	// Respond to the slash command indicating playback has been paused
+// respondEphemeral answers an interaction with a message only the caller sees
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Playback paused.",
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
 		},
 	})
 	if err != nil {
-		log.Printf("Error responding to pauseSlash: %v", err)
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		slog.Warn("Error responding to interaction", "guild_id", i.GuildID, "interaction_id", i.ID, "err", err)
 	}
 }
 
-func (bot *MusicBot) resumeSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	bot.PauseState.Mutex.Lock()
-	defer bot.PauseState.Mutex.Unlock()
-
-	if !bot.PauseState.Paused {
-		// Respond to the slash command indicating playback is not paused
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Playback is not paused.",
-			},
-		})
-		if err != nil {
-			log.Printf("Error responding to resumeSlash: %v", err)
-		}
-		return
-	}
-
-	// Unset the pause flag
-	bot.PauseState.Paused = false
-
-	log.Println("Playback resumed (frames will be sent again).")
+// stop clears the queue, kills ffmpeg, and disconnects from voice
+func (gp *GuildPlayer) stopSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	gp.Stop()
 
-	// Respond to the slash command indicating playback has been resumed
+	// Send response to the slash command
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Playback resumed.",
+			Content: "Playback stopped, queue cleared, and disconnected from the voice channel.",
 		},
 	})
 	if err != nil {
-		log.Printf("Error responding to resumeSlash: %v", err)
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error responding to /stop", "err", err)
 	}
+	return err
 }
 
-// next requests the skip for the current track
-func (bot *MusicBot) nextSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	log.Println("nextSlash command called")
-
-	// Lock the PauseState to safely update it
-	bot.PauseState.Mutex.Lock()
-	bot.PauseState.SkipReq = true
-	if bot.PauseState.Cmd != nil {
-		_ = bot.PauseState.Cmd.Process.Kill()
-	}
-	bot.PauseState.Mutex.Unlock()
+// Stop clears the queue, disconnects from voice and deletes the Now Playing embed
+func (gp *GuildPlayer) Stop() {
+	gp.stopPlayback()
 
-	// Respond to the slash command indicating the current track was skipped
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: "Skipped current track. Moving to the next...",
-		},
-	})
-	if err != nil {
-		log.Printf("Error responding to nextSlash: %v", err)
+	// Delete the current embed message
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		err := gp.Session.ChannelMessageDelete(gp.CurrentSongChannelID, gp.CurrentSongMessageID)
+		if err != nil {
+			gp.log.Warn("Failed to delete Now Playing embed", "err", err)
+		}
+		gp.CurrentSongMessageID = "" // Clear after deletion attempt
+		gp.CurrentSongChannelID = ""
 	}
 }
 
-func (bot *MusicBot) restartSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	log.Println("RestartSlash command called")
+// stopPlayback clears the queue, kills ffmpeg and disconnects from voice
+func (gp *GuildPlayer) stopPlayback() {
+	// Clear the queue and current song first so playQueue doesn't loop them
+	gp.QueueMutex.Lock()
+	gp.Queue = nil
+	gp.CurrentSong = nil
+	gp.resetSkipVotes()
+	gp.QueueMutex.Unlock()
+	gp.stateChanged()
+
+	gp.leaveVoice()
+}
 
-	bot.PauseState.Mutex.Lock()
-	// Stop the current FFmpeg process if it is running
-	if bot.PauseState.Cmd != nil {
-		log.Println("Stopping the current FFmpeg process before restarting...")
-		_ = bot.PauseState.Cmd.Process.Kill()
-		bot.PauseState.Cmd = nil
+// leaveVoice kills ffmpeg, disconnects from voice and resets the pause, seek and
+// skip state. playQueue sees the connection gone and exits on its own, leaving the
+// queue and the current song's position for the next /join or /play.
+func (gp *GuildPlayer) leaveVoice() {
+	// Clear the connection before killing ffmpeg so playQueue doesn't take the
+	// early exit for the end of the song
+	vc := gp.voice.Swap(nil)
+
+	gp.PauseState.Mutex.Lock()
+	if gp.PauseState.Cmd != nil {
+		gp.log.Debug("Stopping ffmpeg", "ffmpeg_pid", gp.PauseState.Cmd.Process.Pid)
+		_ = gp.PauseState.Cmd.Process.Kill()
+		gp.PauseState.Cmd = nil
 	}
+	gp.PauseState.Paused = false
+	gp.PauseState.SkipReq = false
+	gp.PauseState.SeekReq = false
+	gp.PauseState.KillReq = false
+	gp.PauseState.SkipPending = false
+	gp.PauseState.Mutex.Unlock()
+	gp.autoPaused.Store(false)
+
+	// playSong returns promptly once ffmpeg is gone; wait so its last frames go out
+	// over a connection that is still open
+	gp.PlaybackMutex.Lock()
+	gp.PlaybackMutex.Unlock()
+
+	if vc != nil {
+		gp.log.Info("Disconnecting from the voice channel", "channel_id", gp.voiceChannelIDOf(vc))
+		_ = vc.Disconnect()
+	}
+	gp.stateChanged()
+}
 
-	// Reset playback state
-	bot.PauseState.Paused = false
-	bot.PauseState.Pos = 0
-	bot.PauseState.TotalPlayTime = 0
-	bot.PauseState.SkipReq = false
-	bot.PauseState.Mutex.Unlock()
-
-	if bot.CurrentSong != nil {
-		log.Printf("Restarting song: %s", bot.CurrentSong.Name)
-		go func() {
-			// Treat restart as a new session by re-fetching song info
-			song, err := safeFetchSongInfo(bot.CurrentSong.OriginalURL)
-			if err != nil {
-				log.Printf("Error re-fetching song info during restart: %v", err)
-
-				// Respond to the slash command with the error
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: fmt.Sprintf("Error restarting playback: %v", err),
-					},
-				})
-				if err != nil {
-					log.Printf("Error responding to restartSlash: %v", err)
-				}
-				return
-			}
+// pause toggles the paused state
+func (gp *GuildPlayer) pauseSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := gp.Pause(); err != nil {
+		respondMessage(s, i, "Playback is already paused.")
+		return err
+	}
+	respondMessage(s, i, "Playback paused.")
+	return nil
+}
 
-			bot.QueueMutex.Lock()
-			bot.CurrentSong = song // Set the re-fetched song as the current song
-			bot.QueueMutex.Unlock()
+func (gp *GuildPlayer) resumeSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := gp.Resume(); err != nil {
+		respondMessage(s, i, "Playback is not paused.")
+		return err
+	}
+	respondMessage(s, i, "Playback resumed.")
+	return nil
+}
 
-			err = bot.playSong(song)
-			if err != nil {
-				log.Printf("Error restarting playback: %v", err)
+// next skips the current track, or votes to skip it for members who can't control it
+func (gp *GuildPlayer) nextSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	msg, err := gp.skipOrVote(i)
+	switch {
+	case errors.Is(err, ErrNothingPlaying):
+		respondMessage(s, i, "Nothing is playing.")
+		return err
+	case err != nil:
+		respondEphemeral(s, i, fmt.Sprintf("Error: %v", err))
+		return err
+	}
+	respondMessage(s, i, msg)
+	gp.RefreshEmbed()
+	return nil
+}
 
-				// Respond to the slash command with the error
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: fmt.Sprintf("Error restarting playback: %v", err),
-					},
-				})
-				if err != nil {
-					log.Printf("Error responding to restartSlash: %v", err)
-				}
-			} else {
-				// Respond to the slash command indicating success
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: fmt.Sprintf("Restarted song: %s", bot.CurrentSong.Name),
-					},
-				})
-				if err != nil {
-					log.Printf("Error responding to restartSlash: %v", err)
-				}
-			}
-		}()
-	} else {
-		log.Println("No song is currently playing to restart.")
+// restartSlash plays the current song again from the top. It seeks to zero so the
+// playQueue loop stays the only thing running ffmpeg.
+func (gp *GuildPlayer) restartSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := gp.Seek(0); err != nil {
+		respondMessage(s, i, "No song is currently playing to restart.")
+		return err
+	}
 
-		// Respond to the slash command indicating no song to restart
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "No song is currently playing to restart.",
-			},
-		})
-		if err != nil {
-			log.Printf("Error responding to restartSlash: %v", err)
-		}
+	gp.QueueMutex.Lock()
+	song := gp.CurrentSong
+	gp.QueueMutex.Unlock()
+	if song == nil {
+		respondMessage(s, i, "Restarted the song.")
+		return nil
 	}
+	gp.interactionLog(i).Info("Restarting song", "song", song.Name, "song_url", song.OriginalURL)
+	respondMessage(s, i, fmt.Sprintf("Restarted song: %s", song.Name))
+	gp.RefreshEmbed()
+	return nil
 }
 
 func (bot *MusicBot) registerSlashCommands(s *discordgo.Session) error {
@@ -352,19 +440,155 @@ func (bot *MusicBot) registerSlashCommands(s *discordgo.Session) error {
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "url",
-					Description: "The URL of the song to play",
-					Required:    true,
+					Description: "The URL of the song to play, or words to play the first search hit",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "query",
+					Description: "Search for a song and pick from the top results",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "shuffle",
+					Description: "Shuffle the tracks of a playlist before adding them",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "offset",
+					Description: "Skip this many tracks from the start of a playlist",
+					MinValue:    &minPlaylistOffset,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "limit",
+					Description: "Add at most this many tracks from a playlist",
+					MinValue:    &minPlaylistLimit,
 				},
 			},
 		},
 		{
 			Name:        "queue",
-			Description: "Show the current music queue",
+			Description: "Show and edit the music queue",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "show",
+					Description: "Show the current music queue",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Remove a song or range of songs from the queue",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "position",
+							Description: "Position (3) or range (2-5) to remove",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "move",
+					Description: "Move a song to another position",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "from",
+							Description: "Current position of the song",
+							Required:    true,
+							MinValue:    &minQueuePosition,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "to",
+							Description: "New position of the song",
+							Required:    true,
+							MinValue:    &minQueuePosition,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "swap",
+					Description: "Swap two songs in the queue",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "first",
+							Description: "Position of the first song",
+							Required:    true,
+							MinValue:    &minQueuePosition,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "second",
+							Description: "Position of the second song",
+							Required:    true,
+							MinValue:    &minQueuePosition,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "clear",
+					Description: "Clear the queue but keep the current song playing",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "shuffle",
+					Description: "Shuffle the upcoming songs",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "skipto",
+					Description: "Skip straight to a song in the queue",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "position",
+							Description: "Position of the song to play next",
+							Required:    true,
+							MinValue:    &minQueuePosition,
+						},
+					},
+				},
+			},
 		},
 		{
 			Name:        "stop",
 			Description: "Stop the music and clear the queue",
 		},
+		{
+			Name:        "join",
+			Description: "Join your voice channel, or the one given",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "Voice channel to join",
+					ChannelTypes: voiceChannelTypes,
+				},
+			},
+		},
+		{
+			Name:        "leave",
+			Description: "Leave the voice channel, keeping the queue for /join",
+		},
+		{
+			Name:        "move",
+			Description: "Move to another voice channel without interrupting the song",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "Voice channel to move to",
+					Required:     true,
+					ChannelTypes: voiceChannelTypes,
+				},
+			},
+		},
 		{
 			Name:        "pause",
 			Description: "Pause the current song",
@@ -385,6 +609,200 @@ func (bot *MusicBot) registerSlashCommands(s *discordgo.Session) error {
 			Name:        "restart",
 			Description: "Restart the current song",
 		},
+		{
+			Name:        "loop",
+			Description: "Set the loop mode",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "What to repeat",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "off", Value: "off"},
+						{Name: "track", Value: "track"},
+						{Name: "queue", Value: "queue"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "volume",
+			Description: "Show or set the playback volume",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "level",
+					Description: "Volume in percent (0-200)",
+					MinValue:    &minVolumeOption,
+					MaxValue:    maxVolumeOption,
+				},
+			},
+		},
+		{
+			Name:        "library",
+			Description: "Browse and play the local music library",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "search",
+					Description: "Search the library by artist, album or title",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "query",
+							Description: "Words to search for",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "play",
+					Description: "Queue an album, artist or track from the library",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "kind",
+							Description: "What to play",
+							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "album", Value: "album"},
+								{Name: "artist", Value: "artist"},
+								{Name: "track", Value: "track"},
+							},
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "name",
+							Description:  "Album, artist or track to play",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:                     "settings",
+			Description:              "Show or change this server's music settings",
+			DefaultMemberPermissions: &settingsPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "show",
+					Description: "Show the current settings",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "set",
+					Description: "Change one or more settings",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "default_volume",
+							Description: "Volume new sessions start at, in percent",
+							MinValue:    &minVolumeOption,
+							MaxValue:    maxVolumeOption,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "max_queue_length",
+							Description: "Most songs the queue may hold, 0 for no limit",
+							MinValue:    &minMaxQueueLength,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionChannel,
+							Name:         "announce_channel",
+							Description:  "Channel to announce each new song in",
+							ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Name:        "dj_role",
+							Description: "Role that may skip, stop and edit everyone's songs",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "open_controls",
+							Description: "Remove the DJ role so anyone can control playback",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "vote_skip_percent",
+							Description: "Share of listeners that must vote to skip, in percent",
+							MinValue:    &minVoteSkipPercent,
+							MaxValue:    maxVoteSkipPercent,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "fair_queue",
+							Description: "Let requesters take turns instead of playing songs in the order they were added",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "max_user_songs",
+							Description: "Most songs one user may have queued, 0 for no limit",
+							MinValue:    &minUserLimit,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "max_user_minutes",
+							Description: "Most minutes of music one user may have queued, 0 for no limit",
+							MinValue:    &minUserLimit,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "always_on",
+							Description: "24/7 mode: keep playing and stay in voice when nobody is listening",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "reset",
+					Description: "Go back to the bot's default settings",
+				},
+			},
+		},
+		{
+			Name:        "seek",
+			Description: "Jump to a position in the current song",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "timestamp",
+					Description: "Position like 1:23 or 01:02:03, or relative like +30 / -15",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "forward",
+			Description: "Skip ahead in the current song",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "seconds",
+					Description: "How many seconds to skip ahead",
+					Required:    true,
+					MinValue:    &minSeekSeconds,
+				},
+			},
+		},
+		{
+			Name:        "rewind",
+			Description: "Go back in the current song",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "seconds",
+					Description: "How many seconds to go back",
+					Required:    true,
+					MinValue:    &minSeekSeconds,
+				},
+			},
+		},
 	}
 
 	for _, cmd := range commands {
@@ -393,5 +811,6 @@ func (bot *MusicBot) registerSlashCommands(s *discordgo.Session) error {
 			return fmt.Errorf("cannot create '%s' command: %v", cmd.Name, err)
 		}
 	}
+	bot.commandsReady.Store(true)
 	return nil
 }
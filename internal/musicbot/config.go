@@ -0,0 +1,323 @@
+// config.go
+package musicbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the deployment settings. It is loaded from an optional JSON file,
+// then environment variables override individual fields.
+type Config struct {
+	Token                string          `json:"token"`
+	DataDir              string          `json:"data_dir"`
+	MusicDir             string          `json:"music_dir"`
+	Resolvers            []string        `json:"resolvers"`
+	MaxPlaylistTracks    int             `json:"max_playlist_tracks"`
+	AudioFormat          string          `json:"audio_format"`   // yt-dlp -f selector
+	EmbedInterval        Duration        `json:"embed_interval"` // How often the Now Playing embed refreshes
+	StallTimeout         Duration        `json:"stall_timeout"`  // How long a playing guild may send no audio before /healthz fails
+	IdleTimeout          Duration        `json:"idle_timeout"`   // How long the bot stays in voice with nobody listening or nothing queued
+	VoiceTimeout         Duration        `json:"voice_timeout"`  // How long a frame may wait for the voice connection before it counts as lost
+	VoiceRetries         int             `json:"voice_retries"`  // Attempts to rejoin a lost voice connection before giving up
+	StreamRetries        int             `json:"stream_retries"` // Times one song may be resumed after its stream ends early
+	Opus                 OpusConfig      `json:"opus"`
+	Colors               ColorConfig     `json:"colors"`
+	PlaceholderThumbnail string          `json:"placeholder_thumbnail"`
+	GuildDefaults        GuildConfig     `json:"guild_defaults"` // Per-guild settings before /settings overrides
+	HTTPAddr             string          `json:"http_addr"`      // Listen address for /metrics, the API and the dashboard; empty disables them
+	APITokens            []string        `json:"api_tokens"`     // Bearer tokens accepted by the HTTP API; none disables it
+	Dashboard            DashboardConfig `json:"dashboard"`
+	Log                  LogConfig       `json:"log"`
+}
+
+// DashboardConfig enables the web dashboard. It needs either Discord OAuth2
+// credentials or DevLogin, which lets anyone sign in as any user ID and is
+// only meant for local testing.
+type DashboardConfig struct {
+	BaseURL      string   `json:"base_url"` // Public URL of the HTTP server, used for the OAuth2 redirect and library cover art
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	DevLogin     bool     `json:"dev_login"`
+	SessionTTL   Duration `json:"session_ttl"`
+}
+
+// Enabled reports whether any way to sign in to the dashboard is configured
+func (d DashboardConfig) Enabled() bool {
+	return d.ClientID != "" || d.DevLogin
+}
+
+// OpusConfig tunes the encoder; FrameSize is in samples per channel at 48kHz and must be 960
+type OpusConfig struct {
+	Bitrate   int `json:"bitrate"`
+	FrameSize int `json:"frame_size"`
+}
+
+// ColorConfig holds the embed colors as 0xRRGGBB values
+type ColorConfig struct {
+	Playing int `json:"playing"`
+	Error   int `json:"error"`
+	Offline int `json:"offline"`
+}
+
+// GuildConfig holds the settings each guild may override with /settings
+type GuildConfig struct {
+	DefaultVolume     int      `json:"default_volume"`
+	MaxQueueLength    int      `json:"max_queue_length"` // 0 for no limit
+	AnnounceChannelID string   `json:"announce_channel_id"`
+	DJRoleID          string   `json:"dj_role_id"`        // Role allowed to control everyone's songs; empty lets anyone
+	VoteSkipPercent   int      `json:"vote_skip_percent"` // Share of listeners whose votes skip a song for non-DJs
+	FairQueue         bool     `json:"fair_queue"`        // Interleave requesters round-robin instead of appending
+	MaxUserSongs      int      `json:"max_user_songs"`    // Songs one user may have queued, 0 for no limit
+	MaxUserDuration   Duration `json:"max_user_duration"` // Music one user may have queued, 0 for no limit
+	AlwaysOn          bool     `json:"always_on"`         // 24/7 mode: never auto-pause or leave voice when idle
+}
+
+// Duration is a time.Duration written as "1s" or "500ms" in the config file
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// minAPITokenLength keeps guessable tokens out of the API
+const minAPITokenLength = 16
+
+// discordFrameSize is the only frame size that plays correctly: discordgo sends
+// a packet every 20ms, which is 960 samples at 48kHz
+const discordFrameSize = 960
+
+// DefaultConfig returns the settings the bot used before it was configurable
+func DefaultConfig() *Config {
+	return &Config{
+		DataDir:           "data",
+		Resolvers:         DefaultResolverOrder,
+		MaxPlaylistTracks: DefaultMaxPlaylistTracks,
+		AudioFormat:       "bestaudio",
+		EmbedInterval:     Duration{time.Second},
+		StallTimeout:      Duration{30 * time.Second},
+		IdleTimeout:       Duration{5 * time.Minute},
+		VoiceTimeout:      Duration{5 * time.Second},
+		VoiceRetries:      5,
+		StreamRetries:     3,
+		Opus: OpusConfig{
+			Bitrate:   64000,
+			FrameSize: discordFrameSize,
+		},
+		Colors: ColorConfig{
+			Playing: 0x00FF00,
+			Error:   0xFF0000,
+			Offline: 0x808080,
+		},
+		PlaceholderThumbnail: defaultThumbnail,
+		GuildDefaults: GuildConfig{
+			DefaultVolume:   defaultVolume,
+			VoteSkipPercent: 50,
+		},
+		HTTPAddr: ":8080",
+		Dashboard: DashboardConfig{
+			BaseURL:    "http://localhost:8080",
+			SessionTTL: Duration{7 * 24 * time.Hour},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// LoadConfig reads path (skipped when empty) over the defaults, applies
+// environment overrides and validates the result
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open config file: %v", err)
+		}
+		defer f.Close()
+
+		dec := json.NewDecoder(f)
+		// A misspelled key would otherwise be silently ignored
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides fields from environment variables that are set
+func (cfg *Config) applyEnv() error {
+	str := func(dst *string) func(string) error {
+		return func(v string) error { *dst = v; return nil }
+	}
+	num := func(dst *int) func(string) error {
+		return func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			*dst = n
+			return nil
+		}
+	}
+	dur := func(dst *Duration) func(string) error {
+		return func(v string) error {
+			d, err := time.ParseDuration(v)
+			dst.Duration = d
+			return err
+		}
+	}
+
+	overrides := []struct {
+		name string
+		set  func(string) error
+	}{
+		{"BOT_TOKEN", str(&cfg.Token)},
+		{"DATA_DIR", str(&cfg.DataDir)},
+		{"MUSIC_DIR", str(&cfg.MusicDir)},
+		{"RESOLVERS", func(v string) error { cfg.Resolvers = strings.Split(v, ","); return nil }},
+		{"MAX_PLAYLIST_TRACKS", num(&cfg.MaxPlaylistTracks)},
+		{"AUDIO_FORMAT", str(&cfg.AudioFormat)},
+		{"EMBED_INTERVAL", dur(&cfg.EmbedInterval)},
+		{"STALL_TIMEOUT", dur(&cfg.StallTimeout)},
+		{"IDLE_TIMEOUT", dur(&cfg.IdleTimeout)},
+		{"VOICE_TIMEOUT", dur(&cfg.VoiceTimeout)},
+		{"VOICE_RETRIES", num(&cfg.VoiceRetries)},
+		{"STREAM_RETRIES", num(&cfg.StreamRetries)},
+		{"OPUS_BITRATE", num(&cfg.Opus.Bitrate)},
+		{"OPUS_FRAME_SIZE", num(&cfg.Opus.FrameSize)},
+		{"PLACEHOLDER_THUMBNAIL", str(&cfg.PlaceholderThumbnail)},
+		{"DEFAULT_VOLUME", num(&cfg.GuildDefaults.DefaultVolume)},
+		{"MAX_QUEUE_LENGTH", num(&cfg.GuildDefaults.MaxQueueLength)},
+		{"VOTE_SKIP_PERCENT", num(&cfg.GuildDefaults.VoteSkipPercent)},
+		{"FAIR_QUEUE", func(v string) error {
+			b, err := strconv.ParseBool(v)
+			cfg.GuildDefaults.FairQueue = b
+			return err
+		}},
+		{"MAX_USER_SONGS", num(&cfg.GuildDefaults.MaxUserSongs)},
+		{"MAX_USER_DURATION", dur(&cfg.GuildDefaults.MaxUserDuration)},
+		{"ALWAYS_ON", func(v string) error {
+			b, err := strconv.ParseBool(v)
+			cfg.GuildDefaults.AlwaysOn = b
+			return err
+		}},
+		{"HTTP_ADDR", str(&cfg.HTTPAddr)},
+		{"API_TOKENS", func(v string) error { cfg.APITokens = strings.Split(v, ","); return nil }},
+		{"DASHBOARD_URL", str(&cfg.Dashboard.BaseURL)},
+		{"OAUTH_CLIENT_ID", str(&cfg.Dashboard.ClientID)},
+		{"OAUTH_CLIENT_SECRET", str(&cfg.Dashboard.ClientSecret)},
+		{"DASHBOARD_DEV_LOGIN", func(v string) error {
+			b, err := strconv.ParseBool(v)
+			cfg.Dashboard.DevLogin = b
+			return err
+		}},
+		{"LOG_LEVEL", str(&cfg.Log.Level)},
+		{"LOG_FORMAT", str(&cfg.Log.Format)},
+	}
+
+	for _, o := range overrides {
+		v, ok := os.LookupEnv(o.name)
+		if !ok || v == "" {
+			continue
+		}
+		if err := o.set(v); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", o.name, v, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Token != "", "token is required (set BOT_TOKEN)")
+	check(cfg.DataDir != "", "data_dir must not be empty")
+	check(len(cfg.Resolvers) > 0, "at least one resolver is required")
+	check(cfg.MaxPlaylistTracks >= 1, "max_playlist_tracks must be at least 1, got %d", cfg.MaxPlaylistTracks)
+	check(cfg.AudioFormat != "", "audio_format must not be empty")
+	check(cfg.EmbedInterval.Duration >= 500*time.Millisecond, "embed_interval must be at least 500ms, got %s", cfg.EmbedInterval)
+	check(cfg.StallTimeout.Duration >= 5*time.Second, "stall_timeout must be at least 5s, got %s", cfg.StallTimeout)
+	check(cfg.IdleTimeout.Duration >= 10*time.Second, "idle_timeout must be at least 10s, got %s", cfg.IdleTimeout)
+	check(cfg.VoiceTimeout.Duration >= time.Second, "voice_timeout must be at least 1s, got %s", cfg.VoiceTimeout)
+	check(cfg.VoiceRetries >= 1, "voice_retries must be at least 1, got %d", cfg.VoiceRetries)
+	check(cfg.StreamRetries >= 0, "stream_retries must not be negative, got %d", cfg.StreamRetries)
+	check(cfg.Opus.Bitrate >= 6000 && cfg.Opus.Bitrate <= 510000, "opus.bitrate must be between 6000 and 510000, got %d", cfg.Opus.Bitrate)
+	check(cfg.Opus.FrameSize == discordFrameSize, "opus.frame_size must be %d (20ms, what Discord voice expects), got %d", discordFrameSize, cfg.Opus.FrameSize)
+	for _, c := range []struct {
+		name  string
+		value int
+	}{{"playing", cfg.Colors.Playing}, {"error", cfg.Colors.Error}, {"offline", cfg.Colors.Offline}} {
+		check(c.value >= 0 && c.value <= 0xFFFFFF, "colors.%s must be between 0x000000 and 0xFFFFFF", c.name)
+	}
+	check(strings.HasPrefix(cfg.PlaceholderThumbnail, "http"), "placeholder_thumbnail must be an http(s) URL")
+	check(cfg.GuildDefaults.DefaultVolume >= 0 && cfg.GuildDefaults.DefaultVolume <= maxVolume,
+		"guild_defaults.default_volume must be between 0 and %d", maxVolume)
+	check(cfg.GuildDefaults.MaxQueueLength >= 0, "guild_defaults.max_queue_length must not be negative")
+	check(cfg.GuildDefaults.VoteSkipPercent >= 1 && cfg.GuildDefaults.VoteSkipPercent <= 100,
+		"guild_defaults.vote_skip_percent must be between 1 and 100")
+	check(cfg.GuildDefaults.MaxUserSongs >= 0, "guild_defaults.max_user_songs must not be negative")
+	check(cfg.GuildDefaults.MaxUserDuration.Duration >= 0, "guild_defaults.max_user_duration must not be negative")
+	if cfg.Dashboard.ClientID != "" {
+		check(cfg.Dashboard.ClientSecret != "", "dashboard.client_secret is required with dashboard.client_id")
+		check(strings.HasPrefix(cfg.Dashboard.BaseURL, "http"), "dashboard.base_url must be an http(s) URL")
+	}
+	check(cfg.Dashboard.SessionTTL.Duration >= time.Minute, "dashboard.session_ttl must be at least 1m")
+	check(cfg.HTTPAddr != "" || (len(cfg.APITokens) == 0 && !cfg.Dashboard.Enabled()),
+		"http_addr is required for the API and the dashboard")
+	_, err := cfg.Log.level()
+	check(err == nil, "log.level must be debug, info, warn or error, got %q", cfg.Log.Level)
+	check(cfg.Log.Format == "text" || cfg.Log.Format == "json", "log.format must be text or json, got %q", cfg.Log.Format)
+	for n, token := range cfg.APITokens {
+		check(len(token) >= minAPITokenLength, "api_tokens[%d] must be at least %d characters", n, minAPITokenLength)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// thumbnail returns url, or the configured placeholder when the song has none
+func (cfg *Config) thumbnail(url string) string {
+	if url == "" || url == defaultThumbnail {
+		return cfg.PlaceholderThumbnail
+	}
+	return url
+}
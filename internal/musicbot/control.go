@@ -0,0 +1,261 @@
+// control.go
+package musicbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by the playback operations, so callers other than the slash
+// commands (e.g. the HTTP API) can tell them apart
+var (
+	ErrNothingPlaying = errors.New("nothing is currently playing")
+	ErrAlreadyPaused  = errors.New("playback is already paused")
+	ErrNotPaused      = errors.New("playback is not paused")
+	ErrNotInVoice     = errors.New("the bot is not in a voice channel")
+	ErrNotListening   = errors.New("join the bot's voice channel to vote")
+	ErrOtherChannel   = errors.New("the bot is playing to others in another voice channel")
+	ErrUnknownGuild   = errors.New("the bot is not in that server")
+	ErrQueueFull      = errors.New("the queue is full")
+)
+
+// SongInfo is the public view of a Song, without stream URLs or request headers
+type SongInfo struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Duration  int    `json:"duration"` // Seconds, 0 for live streams
+	Thumbnail string `json:"thumbnail"`
+	Uploader  string `json:"uploader,omitempty"`
+	Source    string `json:"source"`
+}
+
+// Info returns the public view of song
+func (song *Song) Info() SongInfo {
+	return SongInfo{
+		Name:      song.Name,
+		URL:       song.OriginalURL,
+		Duration:  song.DurationSeconds,
+		Thumbnail: song.Thumbnail,
+		Uploader:  song.Uploader,
+		Source:    song.Source,
+	}
+}
+
+// PlayerStatus is a point-in-time view of a guild's player
+type PlayerStatus struct {
+	GuildID  string     `json:"guild_id"`
+	Current  *SongInfo  `json:"current"`
+	Position float64    `json:"position"` // Seconds into Current
+	Paused   bool       `json:"paused"`
+	Loop     string     `json:"loop"`
+	Volume   int        `json:"volume"`
+	Queue    []SongInfo `json:"queue"`
+}
+
+// Guild returns the player for a guild the bot is a member of
+func (bot *MusicBot) Guild(guildID string) (*GuildPlayer, error) {
+	if _, err := bot.Session.State.Guild(guildID); err != nil {
+		return nil, ErrUnknownGuild
+	}
+	return bot.player(guildID), nil
+}
+
+// Status returns the current song, queue and playback settings
+func (gp *GuildPlayer) Status() PlayerStatus {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+
+	status := PlayerStatus{
+		GuildID:  gp.GuildID,
+		Position: gp.PauseState.TotalPlayTime + gp.PauseState.Pos,
+		Paused:   gp.PauseState.Paused,
+		Loop:     gp.LoopMode.String(),
+		Volume:   gp.Volume,
+		Queue:    make([]SongInfo, 0, len(gp.Queue)),
+	}
+	if gp.CurrentSong != nil {
+		current := gp.CurrentSong.Info()
+		status.Current = &current
+	}
+	for _, song := range gp.Queue {
+		status.Queue = append(status.Queue, song.Info())
+	}
+	return status
+}
+
+// Pause stops sending audio; ffmpeg keeps its place
+func (gp *GuildPlayer) Pause() error {
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+
+	if gp.PauseState.Paused {
+		return ErrAlreadyPaused
+	}
+	gp.PauseState.Paused = true
+	gp.stateChanged()
+
+	gp.log.Info("Playback paused")
+	return nil
+}
+
+// Resume continues a paused song
+func (gp *GuildPlayer) Resume() error {
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+
+	if !gp.PauseState.Paused {
+		return ErrNotPaused
+	}
+	gp.PauseState.Paused = false
+	gp.autoPaused.Store(false)
+	// Time spent paused doesn't count towards a stall
+	gp.lastFrameAt.Store(time.Now().UnixNano())
+	gp.stateChanged()
+
+	gp.log.Info("Playback resumed")
+	return nil
+}
+
+// Skip ends the current song; playQueue moves on according to the loop mode
+func (gp *GuildPlayer) Skip() error {
+	gp.QueueMutex.Lock()
+	playing := gp.CurrentSong != nil
+	gp.QueueMutex.Unlock()
+	if !playing {
+		return ErrNothingPlaying
+	}
+
+	gp.PauseState.Mutex.Lock()
+	if gp.PauseState.Cmd != nil {
+		gp.PauseState.SkipReq = true
+		gp.PauseState.KillReq = true
+		_ = gp.PauseState.Cmd.Process.Kill()
+	} else {
+		// playQueue is still resolving the stream; playSong skips once ffmpeg starts
+		gp.PauseState.SkipPending = true
+	}
+	gp.PauseState.Mutex.Unlock()
+	return nil
+}
+
+// Enqueue resolves input (a link, or search words played as the first result) and
+// queues it, joining voiceChannelID first unless the bot is already in a channel.
+// Resolving stops when either ctx or the bot shuts down.
+func (gp *GuildPlayer) Enqueue(ctx context.Context, input, voiceChannelID string, opts PlaylistOptions) (EnqueueResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(gp.bot.ctx, cancel)
+	defer stop()
+
+	url, err := gp.searchFallback(ctx, input)
+	if err != nil {
+		return EnqueueResult{}, err
+	}
+
+	if voiceChannelID != "" {
+		if err := gp.joinChannel(voiceChannelID); err != nil {
+			return EnqueueResult{}, fmt.Errorf("Error joining voice channel: %v", err)
+		}
+	} else if gp.voiceConn() == nil {
+		return EnqueueResult{}, ErrNotInVoice
+	}
+
+	res, err := gp.enqueueInput(ctx, url, opts, "")
+	if err == nil {
+		gp.startPlayback()
+	}
+	return res, err
+}
+
+// Watch returns a channel that receives a value whenever the queue or playback
+// state changes, and a function to stop watching. Notifications are coalesced,
+// so a slow reader only misses intermediate changes.
+func (gp *GuildPlayer) Watch() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	gp.watchersMu.Lock()
+	if gp.watchers == nil {
+		gp.watchers = make(map[chan struct{}]struct{})
+	}
+	gp.watchers[ch] = struct{}{}
+	gp.watchersMu.Unlock()
+
+	return ch, func() {
+		gp.watchersMu.Lock()
+		delete(gp.watchers, ch)
+		gp.watchersMu.Unlock()
+	}
+}
+
+func (gp *GuildPlayer) notifyWatchers() {
+	gp.watchersMu.Lock()
+	defer gp.watchersMu.Unlock()
+	for ch := range gp.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// InVoiceChannel reports whether userID is in the voice channel the bot is playing in
+func (gp *GuildPlayer) InVoiceChannel(userID string) bool {
+	channelID := gp.voiceChannelID()
+	if channelID == "" {
+		return false
+	}
+	vs, err := gp.Session.State.VoiceState(gp.GuildID, userID)
+	if err != nil {
+		return false
+	}
+	return vs.ChannelID == channelID
+}
+
+// GuildSummary names a guild the bot is in
+type GuildSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Icon string `json:"icon,omitempty"`
+}
+
+// Guilds lists the guilds the bot is a member of
+func (bot *MusicBot) Guilds() []GuildSummary {
+	bot.Session.State.RLock()
+	defer bot.Session.State.RUnlock()
+
+	guilds := make([]GuildSummary, 0, len(bot.Session.State.Guilds))
+	for _, g := range bot.Session.State.Guilds {
+		guilds = append(guilds, GuildSummary{ID: g.ID, Name: g.Name, Icon: g.IconURL("64")})
+	}
+	return guilds
+}
+
+// RefreshEmbed redraws the Now Playing embed after a change made outside Discord
+func (gp *GuildPlayer) RefreshEmbed() {
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		gp.updateNowPlayingEmbed(gp.Session)
+	}
+}
+
+// searchFallback returns input unchanged when a resolver can handle it, or
+// the first search result for it otherwise
+func (gp *GuildPlayer) searchFallback(ctx context.Context, input string) (string, error) {
+	if gp.bot.Resolvers.Match(input) {
+		return input, nil
+	}
+
+	logFrom(ctx).Info("Input is not a link, playing the first search result", "input", input)
+	results, err := searchSongs(ctx, input, 1)
+	if err != nil {
+		logFrom(ctx).Error("Error searching", "input", input, "err", err)
+		return "", fmt.Errorf("Error searching for song: %v", err)
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("No results found for **%s**.", input)
+	}
+	return results[0].URL, nil
+}
@@ -3,37 +3,29 @@ package musicbot
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func (bot *MusicBot) updateNowPlayingEmbed(s *discordgo.Session) {
+func (gp *GuildPlayer) updateNowPlayingEmbed(s *discordgo.Session) {
 	// Only proceed if the Now Playing embed is initialized
-	if !bot.EmbedInitialized {
-		log.Println("Cannot update Now Playing embed: Embed not initialized.")
+	if !gp.EmbedInitialized || gp.CurrentSongMessageID == "" || gp.CurrentSongChannelID == "" {
+		gp.log.Debug("Cannot update Now Playing embed: embed not initialized")
 		return
 	}
-	if bot.CurrentSongMessageID == "" || bot.CurrentSongChannelID == "" {
-		log.Println("Cannot update Now Playing embed: Missing or invalid message/channel ID")
+	if gp.CurrentSong == nil {
+		gp.log.Debug("Cannot update Now Playing embed: no current song")
 		return
 	}
 
-	if bot.CurrentSong == nil {
-		log.Println("Cannot update Now Playing embed: No current song")
-		return
-	}
-
-	// Lock PauseState for thread safety
-	bot.PauseState.Mutex.Lock()
-	elapsed := int(bot.PauseState.Pos)
-	totalDuration := bot.CurrentSong.DurationSeconds
-	bot.PauseState.Mutex.Unlock()
+	elapsed := int(gp.Position())
+	totalDuration := gp.CurrentSong.DurationSeconds
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Now Playing:",
-		Description: fmt.Sprintf("🎵 **[%s](%s)**", bot.CurrentSong.Name, bot.CurrentSong.OriginalURL),
-		Color:       0x00FF00,
+		Description: fmt.Sprintf("🎵 **[%s](%s)**", gp.CurrentSong.Name, gp.CurrentSong.OriginalURL),
+		Color:       gp.bot.Config.Colors.Playing,
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name: "Duration",
@@ -45,177 +37,260 @@ func (bot *MusicBot) updateNowPlayingEmbed(s *discordgo.Session) {
 			{
 				Name: "State",
 				Value: func() string {
-					if bot.PauseState.Paused {
+					if gp.PauseState.Paused && gp.autoPaused.Load() {
+						return "⏸ Paused until someone joins"
+					}
+					if gp.PauseState.Paused {
 						return "⏸ Paused"
 					}
 					return "▶️ Playing"
 				}(),
 				Inline: true,
 			},
+			{
+				Name:   "Loop",
+				Value:  gp.loopMode().Label(),
+				Inline: true,
+			},
+			{
+				Name:   "Volume",
+				Value:  fmt.Sprintf("🔊 %d%%", gp.volume()),
+				Inline: true,
+			},
 		},
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: bot.CurrentSong.Thumbnail,
+			URL: gp.bot.Config.thumbnail(gp.CurrentSong.Thumbnail),
 		},
 	}
 
+	if votes := gp.skipVoteProgress(); votes.Votes > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Skip votes",
+			Value:  fmt.Sprintf("🗳 %d/%d", votes.Votes, votes.Needed),
+			Inline: true,
+		})
+	}
+
+	components := gp.nowPlayingComponents()
 	edit := &discordgo.MessageEdit{
-		Channel: bot.CurrentSongChannelID,
-		ID:      bot.CurrentSongMessageID,
-		Embed:   embed,
+		Channel:    gp.CurrentSongChannelID,
+		ID:         gp.CurrentSongMessageID,
+		Embed:      embed,
+		Components: &components,
 	}
 
 	_, err := s.ChannelMessageEditComplex(edit)
 	if err != nil {
-		log.Printf("Failed to update Now Playing embed: %v", err)
-		return
+		metrics.discordAPIErrors.inc(callMessageEdit)
+		gp.log.Warn("Failed to update Now Playing embed", "err", err)
 	}
-
-	log.Println("Now Playing embed updated successfully.")
 }
 
+// maxQueueListed caps how many upcoming songs are rendered so the embed stays under Discord's limits
+const maxQueueListed = 20
+
 // listQueue sends an embed with the current queue
-func (bot *MusicBot) listQueueSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	log.Println("listQueueSlash command called")
+func (gp *GuildPlayer) listQueueSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return gp.respondQueue(s, i, "")
+}
 
-	bot.QueueMutex.Lock()
-	defer bot.QueueMutex.Unlock()
+// respondQueue answers the interaction with an optional message and the resulting queue
+func (gp *GuildPlayer) respondQueue(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	gp.QueueMutex.Lock()
+	embed := gp.queueEmbed()
+	gp.QueueMutex.Unlock()
 
-	if len(bot.Queue) == 0 && bot.CurrentSong == nil {
-		embed := &discordgo.MessageEmbed{
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Embeds:  []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Failed to respond to slash command", "err", err)
+	}
+	return err
+}
+
+// queueEmbed renders the current song and upcoming queue; the caller must hold QueueMutex
+func (gp *GuildPlayer) queueEmbed() *discordgo.MessageEmbed {
+	if len(gp.Queue) == 0 && gp.CurrentSong == nil {
+		return &discordgo.MessageEmbed{
 			Title:       "Queue is Empty!",
 			Description: "Add songs to the queue with `/play <url>`.",
-			Color:       0xFF0000,
-		}
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Embeds: []*discordgo.MessageEmbed{embed},
-			},
-		})
-		if err != nil {
-			log.Printf("Failed to respond to slash command: %v", err)
+			Color:       gp.bot.Config.Colors.Error,
 		}
-		return
 	}
 
 	var description string
 	var thumbURL string
 
-	if bot.CurrentSong != nil {
+	if gp.CurrentSong != nil {
 		description += fmt.Sprintf("🎵 **Now Playing**: [%s](%s)\nDuration: %s\n\n",
-			bot.CurrentSong.Name, bot.CurrentSong.OriginalURL, bot.CurrentSong.Duration)
-		thumbURL = bot.CurrentSong.Thumbnail // Use the thumbnail of the current song
+			gp.CurrentSong.Name, gp.CurrentSong.OriginalURL, gp.CurrentSong.Duration)
+		thumbURL = gp.bot.Config.thumbnail(gp.CurrentSong.Thumbnail) // Use the thumbnail of the current song
 	}
 
-	if len(bot.Queue) > 0 {
+	if len(gp.Queue) > 0 {
 		description += "**Up Next:**\n"
-		for i, song := range bot.Queue {
-			description += fmt.Sprintf("%d. [%s](%s) (%s)\n", i+1, song.Name, song.OriginalURL, song.Duration)
+		for i, song := range gp.Queue {
+			if i == maxQueueListed {
+				description += fmt.Sprintf("...and %d more\n", len(gp.Queue)-maxQueueListed)
+				break
+			}
+			description += fmt.Sprintf("%d. [%s](%s) (%s)", i+1, song.Name, song.OriginalURL, song.Duration)
+			if song.RequesterID != "" {
+				description += " · <@" + song.RequesterID + ">"
+			}
+			description += "\n"
 		}
 	}
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Music Queue",
 		Description: description,
-		Color:       0x00FF00,
+		Color:       gp.bot.Config.Colors.Playing,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
 			URL: thumbURL, // Use the current song thumbnail if available
 		},
 	}
+	if gp.bot.guildConfig(gp.GuildID).FairQueue {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "Fair queue: requesters take turns"}
+	}
+	return embed
+}
 
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
+// nowPlayingComponents builds the control buttons shown under the Now Playing embed
+func (gp *GuildPlayer) nowPlayingComponents() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Style: discordgo.PrimaryButton, Label: "Pause", CustomID: "pause_button"},
+				discordgo.Button{Style: discordgo.SuccessButton, Label: "Resume", CustomID: "resume_button"},
+				discordgo.Button{Style: discordgo.SecondaryButton, Label: "Restart", CustomID: "restart_button"},
+				discordgo.Button{Style: discordgo.DangerButton, Label: "Stop", CustomID: "stop_button"},
+				discordgo.Button{Style: discordgo.SecondaryButton, Label: "Loop: " + gp.loopMode().String(), CustomID: "loop_button"},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Style: discordgo.PrimaryButton, Label: "Skip", CustomID: "skip_button"},
+			},
 		},
-	})
-	if err != nil {
-		log.Printf("Failed to respond to slash command: %v", err)
 	}
 }
 
-// handleComponentInteraction processes button clicks for pause, resume, restart, and stop
-func (bot *MusicBot) handleComponentInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// handleComponentInteraction processes button clicks for pause, resume, restart, stop and loop
+func (gp *GuildPlayer) handleComponentInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Search pickers answer on their own since they edit the picker message
+	if strings.HasPrefix(i.MessageComponentData().CustomID, searchSelectPrefix) {
+		gp.handleSearchSelect(s, i)
+		return
+	}
+	// Skip answers on its own since non-DJs only get a vote
+	if i.MessageComponentData().CustomID == "skip_button" {
+		gp.skipButton(s, i)
+		return
+	}
+
+	if command, ok := buttonCommands[i.MessageComponentData().CustomID]; ok {
+		if _, owners := gp.commandOwners(command, i); !gp.authorize(s, i, owners) {
+			gp.interactionLog(i).Info("Control button denied", "command", command)
+			return
+		}
+	}
+
 	// Properly acknowledge the button interaction
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseUpdateMessage, // Use a valid type for updating the message
 	})
 	if err != nil {
-		log.Printf("Error acknowledging button interaction: %v", err)
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error acknowledging button interaction", "err", err)
 		return
 	}
 
-	// Process the button interaction based on CustomID
+	// The click was acknowledged by updating the message, so the control methods run
+	// directly; the slash handlers would try to respond a second time
 	switch i.MessageComponentData().CustomID {
 	case "pause_button":
-		bot.pauseSlash(s, i)
+		err = gp.Pause()
 	case "resume_button":
-		bot.resumeSlash(s, i)
+		err = gp.Resume()
 	case "restart_button":
-		bot.restartSlash(s, i)
+		err = gp.Seek(0)
 	case "stop_button":
-		bot.stopSlash(s, i)
+		gp.Stop()
+	case "loop_button":
+		gp.cycleLoopMode()
 	default:
-		log.Printf("Unhandled button ID: %s", i.MessageComponentData().CustomID)
+		gp.interactionLog(i).Warn("Unhandled button", "custom_id", i.MessageComponentData().CustomID)
+	}
+	if err != nil {
+		gp.interactionLog(i).Info("Button had no effect", "custom_id", i.MessageComponentData().CustomID, "err", err)
 	}
 
 	// Update the "Now Playing" embed if initialized
-	if bot.CurrentSongMessageID != "" && bot.CurrentSongChannelID != "" {
-		bot.updateNowPlayingEmbed(s)
-	} else {
-		log.Println("Cannot update Now Playing embed: Embed not yet initialized.")
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		gp.updateNowPlayingEmbed(s)
 	}
 }
 
 // nowPlaying displays the current song with its duration and elapsed time
-func (bot *MusicBot) nowPlayingSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if bot.CurrentSong == nil {
+func (gp *GuildPlayer) nowPlayingSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if gp.CurrentSong == nil {
 		embed := &discordgo.MessageEmbed{
 			Title:       "Nothing is currently playing.",
 			Description: "Add a song to the queue with `/play <url>`!",
-			Color:       0xFF0000,
+			Color:       gp.bot.Config.Colors.Error,
 		}
-		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Embeds: []*discordgo.MessageEmbed{embed},
 			},
 		})
-		return
+		if err != nil {
+			metrics.discordAPIErrors.inc(callInteractionRespond)
+			gp.interactionLog(i).Warn("Failed to respond to slash command", "err", err)
+			return err
+		}
+		return ErrNothingPlaying
 	}
 
-	bot.PauseState.Mutex.Lock()
-	elapsed := int(bot.PauseState.Pos)
-	bot.PauseState.Mutex.Unlock()
+	elapsed := int(gp.Position())
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Now Playing:",
-		Description: fmt.Sprintf("🎵 **[%s](%s)**", bot.CurrentSong.Name, bot.CurrentSong.OriginalURL),
-		Color:       0x00FF00,
+		Description: fmt.Sprintf("🎵 **[%s](%s)**", gp.CurrentSong.Name, gp.CurrentSong.OriginalURL),
+		Color:       gp.bot.Config.Colors.Playing,
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name: "Duration",
 				Value: fmt.Sprintf("[%02d:%02d] / [%02d:%02d]",
 					elapsed/60, elapsed%60,
-					bot.CurrentSong.DurationSeconds/60, bot.CurrentSong.DurationSeconds%60),
+					gp.CurrentSong.DurationSeconds/60, gp.CurrentSong.DurationSeconds%60),
+				Inline: true,
+			},
+			{
+				Name:   "Loop",
+				Value:  gp.loopMode().Label(),
+				Inline: true,
+			},
+			{
+				Name:   "Volume",
+				Value:  fmt.Sprintf("🔊 %d%%", gp.volume()),
 				Inline: true,
 			},
 		},
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: bot.CurrentSong.Thumbnail,
+			URL: gp.bot.Config.thumbnail(gp.CurrentSong.Thumbnail),
 		},
 	}
 
-	components := []discordgo.MessageComponent{
-		discordgo.ActionsRow{
-			Components: []discordgo.MessageComponent{
-				discordgo.Button{Style: discordgo.PrimaryButton, Label: "Pause", CustomID: "pause_button"},
-				discordgo.Button{Style: discordgo.SuccessButton, Label: "Resume", CustomID: "resume_button"},
-				discordgo.Button{Style: discordgo.SecondaryButton, Label: "Restart", CustomID: "restart_button"},
-				discordgo.Button{Style: discordgo.DangerButton, Label: "Stop", CustomID: "stop_button"},
-			},
-		},
-	}
+	components := gp.nowPlayingComponents()
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -225,21 +300,21 @@ func (bot *MusicBot) nowPlayingSlash(s *discordgo.Session, i *discordgo.Interact
 		},
 	})
 	if err != nil {
-		log.Printf("Error sending Now Playing embed: %v", err)
-		return
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error sending Now Playing embed", "err", err)
+		return err
 	}
 
-	// Retrieve message details after responding
-	msg, followErr := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-		Content: " ",
-	})
-	if followErr != nil {
-		log.Printf("Error retrieving follow-up message: %v", followErr)
-		return
+	// Retrieve the response message so the ticker and controls can edit it
+	msg, err := s.InteractionResponse(i.Interaction)
+	if err != nil {
+		// The embed was sent, it just won't be kept up to date
+		gp.interactionLog(i).Warn("Error retrieving Now Playing message", "err", err)
+		return nil
 	}
 
-	bot.CurrentSongMessageID = msg.ID
-	bot.CurrentSongChannelID = i.ChannelID
-	log.Println("Now Playing embed created successfully.")
-
+	gp.CurrentSongMessageID = msg.ID
+	gp.CurrentSongChannelID = i.ChannelID
+	gp.EmbedInitialized = true
+	return nil
 }
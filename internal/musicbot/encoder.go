@@ -4,16 +4,23 @@ import "layeh.com/gopus"
 
 // OpusEncoder is a wrapper around the gopus.Encoder
 type OpusEncoder struct {
-	encoder *gopus.Encoder
+	encoder   *gopus.Encoder
+	frameSize int
 }
 
 // newOpusEncoder constructs a new Gopus encoder set to 48kHz stereo
-func newOpusEncoder() (*OpusEncoder, error) {
+func newOpusEncoder(cfg OpusConfig) (*OpusEncoder, error) {
 	enc, err := gopus.NewEncoder(48000, 2, gopus.Audio)
 	if err != nil {
 		return nil, err
 	}
-	return &OpusEncoder{encoder: enc}, nil
+	enc.SetBitrate(cfg.Bitrate)
+	return &OpusEncoder{encoder: enc, frameSize: cfg.FrameSize}, nil
+}
+
+// pcmFrameBytes is the size of one frame of s16le stereo PCM
+func (oe *OpusEncoder) pcmFrameBytes() int {
+	return oe.frameSize * 2 * 2
 }
 
 // Encode takes 16-bit PCM data, encodes it to Opus, and returns the encoded bytes
@@ -24,8 +31,8 @@ func (oe *OpusEncoder) Encode(pcm []byte) ([]byte, error) {
 		pcmData[i] = int16(pcm[2*i]) | int16(pcm[2*i+1])<<8
 	}
 
-	// 960 samples at 48 kHz = 20ms of audio
-	opusBuf, err := oe.encoder.Encode(pcmData, 960, 4000)
+	// frameSize samples per channel, e.g. 960 at 48 kHz = 20ms of audio
+	opusBuf, err := oe.encoder.Encode(pcmData, oe.frameSize, 4000)
 	if err != nil {
 		return nil, err
 	}
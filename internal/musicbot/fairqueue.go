@@ -0,0 +1,75 @@
+// fairqueue.go
+package musicbot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUserLimit is returned when a requester already has as much queued as the guild allows
+var ErrUserLimit = errors.New("you have reached your queue limit")
+
+// minUserLimit is the lower bound of the /settings per-user limit options; 0 removes the limit
+var minUserLimit = 0.0
+
+// userLimit trims songs to what requesterID may still queue under the guild's
+// per-user limits, and says why when it cut any; the caller must hold QueueMutex
+func (gp *GuildPlayer) userLimit(songs []*Song, requesterID string, cfg GuildConfig) ([]*Song, string) {
+	// Songs queued through the API aren't anyone's to limit
+	if requesterID == "" || (cfg.MaxUserSongs == 0 && cfg.MaxUserDuration.Duration == 0) {
+		return songs, ""
+	}
+
+	count, total := 0, 0
+	for _, song := range gp.Queue {
+		if song.RequesterID == requesterID {
+			count++
+			total += song.DurationSeconds
+		}
+	}
+
+	maxSeconds := int(cfg.MaxUserDuration.Duration / time.Second)
+	for n, song := range songs {
+		if cfg.MaxUserSongs > 0 && count+n >= cfg.MaxUserSongs {
+			return songs[:n], fmt.Sprintf("you can have at most %d songs queued at once", cfg.MaxUserSongs)
+		}
+		if maxSeconds > 0 && total+song.DurationSeconds > maxSeconds {
+			return songs[:n], fmt.Sprintf("you can have at most %s of music queued at once", formatDuration(maxSeconds))
+		}
+		total += song.DurationSeconds
+	}
+	return songs, ""
+}
+
+// fairInsert adds songs so requesters take turns. A song's round is how many
+// songs its requester has ahead of it, counting the current one; each new song
+// goes after its requester's other songs and every song from the same or an
+// earlier round. The caller must hold QueueMutex.
+func (gp *GuildPlayer) fairInsert(songs []*Song) {
+	for _, song := range songs {
+		rounds := make(map[string]int)
+		if gp.CurrentSong != nil {
+			rounds[gp.CurrentSong.RequesterID] = 1
+		}
+		queuedRounds := make([]int, len(gp.Queue))
+		last := -1
+		for n, queued := range gp.Queue {
+			queuedRounds[n] = rounds[queued.RequesterID]
+			rounds[queued.RequesterID]++
+			if queued.RequesterID == song.RequesterID {
+				last = n
+			}
+		}
+
+		round := rounds[song.RequesterID]
+		at := len(gp.Queue)
+		for n := last + 1; n < len(gp.Queue); n++ {
+			if queuedRounds[n] > round {
+				at = n
+				break
+			}
+		}
+		gp.Queue = append(gp.Queue[:at], append([]*Song{song}, gp.Queue[at:]...)...)
+	}
+}
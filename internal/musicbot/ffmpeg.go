@@ -4,34 +4,46 @@ package musicbot
 import (
 	"fmt"
 	"io"
-	"log"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 )
 
 // playSong handles spawning FFmpeg, reading PCM, encoding to Opus, and sending it to Discord
-func (bot *MusicBot) playSong(song *Song) error {
-	log.Printf("Starting new song: %s", song.Name)
-
-	// Reset start position on restart
-	bot.PauseState.Mutex.Lock()
-	startPos := bot.PauseState.TotalPlayTime + bot.PauseState.Pos
-	if bot.PauseState.Pos == 0 {
-		startPos = 0
+func (gp *GuildPlayer) playSong(song *Song) error {
+	lg := gp.log.With("song_url", song.OriginalURL)
+	// One connection for the whole run; a /move or reconnect swaps it under us
+	vc := gp.voiceConn()
+	if vc == nil {
+		return ErrNotInVoice
 	}
-	log.Printf("Starting position for playback: %.2f seconds", startPos)
-	bot.PauseState.Mutex.Unlock()
+
+	// Resume from wherever the previous run of this song stopped (or was seeked to);
+	// Pos restarts at zero since ffmpeg reports progress relative to -ss
+	gp.PauseState.Mutex.Lock()
+	startPos := gp.PauseState.TotalPlayTime + gp.PauseState.Pos
+	gp.PauseState.TotalPlayTime = startPos
+	gp.PauseState.Pos = 0
+	gp.PauseState.Mutex.Unlock()
 
 	cmdArgs := []string{
 		"-ss", fmt.Sprintf("%.2f", startPos),
+	}
+	if headers := ffmpegHeaders(song.HTTPHeaders); headers != "" {
+		cmdArgs = append(cmdArgs, "-headers", headers)
+	}
+	if isHTTPStream(song.StreamURL) {
+		cmdArgs = append(cmdArgs, httpReconnectArgs...)
+	}
+	cmdArgs = append(cmdArgs,
 		"-i", song.StreamURL,
 		"-ac", "2",
 		"-f", "s16le",
 		"-ar", "48000",
 		"pipe:1",
 		"-progress", "pipe:2",
-	}
+	)
 
 	cmd := exec.Command("ffmpeg", cmdArgs...)
 	ffmpegOut, err := cmd.StdoutPipe()
@@ -43,97 +55,146 @@ func (bot *MusicBot) playSong(song *Song) error {
 		return fmt.Errorf("ffmpeg stderr pipe error: %v", err)
 	}
 	if err := cmd.Start(); err != nil {
+		metrics.ffmpegSpawnFailures.inc()
 		return fmt.Errorf("error starting ffmpeg: %v", err)
 	}
+	lg = lg.With("ffmpeg_pid", cmd.Process.Pid)
+	lg.Debug("Started ffmpeg", "start_pos", startPos)
+
+	// The stall clock starts now, so a stream that never produces audio is caught too
+	gp.lastFrameAt.Store(time.Now().UnixNano())
+	gp.PauseState.Mutex.Lock()
+	gp.PauseState.Cmd = cmd
+	if gp.PauseState.SkipPending {
+		// Skipped while the stream was being resolved; end it like any other skip
+		gp.PauseState.SkipPending = false
+		gp.PauseState.SkipReq = true
+		gp.PauseState.KillReq = true
+		_ = cmd.Process.Kill()
+	}
+	gp.PauseState.Mutex.Unlock()
 
-	bot.PauseState.Mutex.Lock()
-	bot.PauseState.Cmd = cmd
-	bot.PauseState.Mutex.Unlock()
-
-	bot.VoiceConn.Speaking(true)
+	vc.Speaking(true)
 
 	progressChan := make(chan float64)
 	doneChan := make(chan error)
-	go bot.parseFFmpegProgress(ffmpegErr, progressChan, doneChan)
+	go gp.parseFFmpegProgress(ffmpegErr, progressChan, doneChan)
 
-	opusEncoder, err := newOpusEncoder()
+	opusEncoder, err := newOpusEncoder(gp.bot.Config.Opus)
 	if err != nil {
 		return fmt.Errorf("error creating opus encoder: %v", err)
 	}
 	defer opusEncoder.Close()
 
 	// Periodic embed update goroutine
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(gp.bot.Config.EmbedInterval.Duration)
 	go func() {
 		for range ticker.C {
-			if bot.PauseState.Paused || bot.CurrentSong == nil || bot.CurrentSongMessageID == "" || bot.CurrentSongChannelID == "" {
-				log.Println("Ticker skipped: Embed not yet initialized.")
+			if gp.PauseState.Paused || gp.CurrentSong == nil || gp.CurrentSongMessageID == "" || gp.CurrentSongChannelID == "" {
 				continue
 			}
-			bot.updateNowPlayingEmbed(bot.Session)
+			gp.updateNowPlayingEmbed(gp.Session)
 		}
 	}()
 
-	rawBuf := make([]byte, 960*4)
+	rawBuf := make([]byte, opusEncoder.pcmFrameBytes())
+	voiceTimeout := gp.bot.Config.VoiceTimeout.Duration
+	sendTimer := time.NewTimer(voiceTimeout)
+	defer sendTimer.Stop()
+	var playErr error
 	for {
 		select {
 		case err := <-doneChan:
 			ticker.Stop()
 			if err != nil {
-				log.Printf("Error parsing FFmpeg progress: %v", err)
+				lg.Warn("Error parsing ffmpeg progress", "err", err)
 			}
 			goto cleanup
 
 		case progress := <-progressChan:
-			bot.PauseState.Mutex.Lock()
-			bot.PauseState.Pos = progress
-			bot.PauseState.Mutex.Unlock()
+			gp.PauseState.Mutex.Lock()
+			// Late progress from a killed ffmpeg must not clobber the seek target
+			if !gp.PauseState.SeekReq {
+				gp.PauseState.Pos = progress
+			}
+			gp.PauseState.Mutex.Unlock()
 
 		default:
-			bot.PauseState.Mutex.Lock()
-			paused := bot.PauseState.Paused
-			skip := bot.PauseState.SkipReq
-			bot.PauseState.Mutex.Unlock()
+			gp.PauseState.Mutex.Lock()
+			paused := gp.PauseState.Paused
+			skip := gp.PauseState.SkipReq
+			volume := gp.Volume
+			gp.PauseState.Mutex.Unlock()
 
 			if paused || skip {
 				break
 			}
 
-			n, err := ffmpegOut.Read(rawBuf)
+			// Read whole frames so volume scaling never splits a sample
+			n, err := io.ReadFull(ffmpegOut, rawBuf)
 			if err != nil {
-				if err == io.EOF {
+				if err == io.ErrUnexpectedEOF {
+					metrics.framesDropped.inc("partial_frame")
+				}
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
 					break
 				}
-				log.Printf("Error reading ffmpeg output: %v", err)
+				lg.Warn("Error reading ffmpeg output", "err", err)
 				break
 			}
-			if n == 0 {
-				continue
-			}
+
+			applyVolume(rawBuf[:n], volume)
 
 			opusBuf, err := opusEncoder.Encode(rawBuf[:n])
 			if err != nil {
-				log.Printf("Error encoding to Opus: %v", err)
+				lg.Warn("Error encoding to Opus", "err", err)
+				metrics.encodeErrors.inc()
+				metrics.framesDropped.inc("encode_error")
 				break
 			}
-			bot.VoiceConn.OpusSend <- opusBuf
+			metrics.framesEncoded.inc()
+			sendTimer.Reset(voiceTimeout)
+			select {
+			case vc.OpusSend <- opusBuf:
+				metrics.framesSent.inc()
+				gp.lastFrameAt.Store(time.Now().UnixNano())
+			case <-sendTimer.C:
+				// The voice websocket dropped or Discord moved us; nothing is draining OpusSend
+				metrics.framesDropped.inc("voice_timeout")
+				lg.Warn("Voice connection stopped taking audio", "timeout", voiceTimeout)
+				playErr = errVoiceLost
+				goto cleanup
+			case <-gp.bot.ctx.Done():
+				// Shutdown kills ffmpeg next; don't block on a voice connection that may be gone
+				metrics.framesDropped.inc("shutdown")
+			}
 		}
 	}
 
 cleanup:
 	ticker.Stop()
-	bot.VoiceConn.Speaking(false)
+	vc.Speaking(false)
 	_ = cmd.Process.Kill()
+	if playErr != nil {
+		// Left before ffmpeg finished; let the progress reader see EOF and exit
+		for done := false; !done; {
+			select {
+			case <-progressChan:
+			case <-doneChan:
+				done = true
+			}
+		}
+	}
 
-	bot.PauseState.Mutex.Lock()
-	bot.PauseState.Cmd = nil
-	bot.PauseState.Mutex.Unlock()
+	gp.PauseState.Mutex.Lock()
+	gp.PauseState.Cmd = nil
+	gp.PauseState.Mutex.Unlock()
 
-	return nil
+	return playErr
 }
 
 // parseFFmpegProgress continuously reads FFmpeg stderr to update the playback position
-func (bot *MusicBot) parseFFmpegProgress(reader io.Reader, progressChan chan<- float64, done chan<- error) {
+func (gp *GuildPlayer) parseFFmpegProgress(reader io.Reader, progressChan chan<- float64, done chan<- error) {
 	defer close(progressChan)
 	defer close(done)
 
@@ -168,3 +229,18 @@ func (bot *MusicBot) parseFFmpegProgress(reader io.Reader, progressChan chan<- f
 		}
 	}
 }
+
+// ffmpegHeaders formats the HTTP headers yt-dlp requires into ffmpeg's -headers value
+func ffmpegHeaders(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, headers[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+// ffprobe.go
+package musicbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// audioExtensions are the file extensions the http and file backends accept
+var audioExtensions = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true,
+	".m4a": true, ".aac": true, ".wav": true, ".aiff": true, ".wma": true,
+}
+
+// probeResult is what ffprobe tells us about an audio file or stream
+type probeResult struct {
+	Title    string
+	Artist   string
+	Album    string
+	Track    int // Track number within the disc, 0 if untagged
+	Disc     int
+	Duration float64
+	Codec    string
+	Bitrate  float64 // kbit/s
+	HasCover bool    // An embedded picture stream is present
+}
+
+// probeAudio runs ffprobe on a local path or URL
+func probeAudio(ctx context.Context, target string) (*probeResult, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", target)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		slog.Warn("ffprobe failed", "target", target, "err", err, "stderr", stderr.String())
+		return nil, fmt.Errorf("ffprobe error: %v\n%s", err, stderr.String())
+	}
+
+	var out struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			BitRate  string            `json:"bit_rate"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType   string            `json:"codec_type"`
+			CodecName   string            `json:"codec_name"`
+			Tags        map[string]string `json:"tags"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("could not parse ffprobe output: %v", err)
+	}
+
+	res := &probeResult{}
+	res.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	if bitRate, err := strconv.ParseFloat(out.Format.BitRate, 64); err == nil {
+		res.Bitrate = bitRate / 1000
+	}
+
+	hasAudio := false
+	for _, st := range out.Streams {
+		switch {
+		case st.CodecType == "audio" && !hasAudio:
+			hasAudio = true
+			res.Codec = st.CodecName
+			// Ogg/Opus files keep their tags on the stream rather than the container
+			if len(out.Format.Tags) == 0 {
+				out.Format.Tags = st.Tags
+			}
+		case st.CodecType == "video" && st.Disposition.AttachedPic == 1:
+			res.HasCover = true
+		}
+	}
+	if !hasAudio {
+		return nil, fmt.Errorf("%s has no audio stream", target)
+	}
+
+	res.Title = tagValue(out.Format.Tags, "title")
+	res.Artist = tagValue(out.Format.Tags, "artist")
+	res.Album = tagValue(out.Format.Tags, "album")
+	res.Track = leadingInt(tagValue(out.Format.Tags, "track"))
+	res.Disc = leadingInt(tagValue(out.Format.Tags, "disc"))
+	return res, nil
+}
+
+// leadingInt parses tags like "3/12" as 3, returning 0 when there's no number
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// tagValue looks a tag up case-insensitively, since containers disagree on "title" vs "TITLE"
+func tagValue(tags map[string]string, key string) string {
+	for k, v := range tags {
+		if strings.EqualFold(k, key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// songFromProbe builds a Song for a directly playable file or URL
+func songFromProbe(probe *probeResult, streamURL, fallbackName string) *Song {
+	name := probe.Title
+	if name == "" {
+		name = fallbackName
+	}
+	if probe.Artist != "" {
+		name = probe.Artist + " - " + name
+	}
+
+	duration := int(probe.Duration)
+	return &Song{
+		Name:            name,
+		StreamURL:       streamURL,
+		Duration:        formatDuration(duration),
+		DurationSeconds: duration,
+		Thumbnail:       defaultThumbnail,
+		OriginalURL:     streamURL,
+		ResolvedAt:      time.Now(),
+		Uploader:        probe.Artist,
+		Codec:           probe.Codec,
+		Bitrate:         probe.Bitrate,
+	}
+}
+
+// httpResolver plays direct links to audio files, probing them with ffprobe
+type httpResolver struct{}
+
+func (httpResolver) Name() string { return "http" }
+
+func (httpResolver) Match(input string) bool {
+	if !isURL(input) {
+		return false
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return false
+	}
+	return audioExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+func (httpResolver) Resolve(ctx context.Context, input string) ([]*Song, error) {
+	probe, err := probeAudio(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	name := input
+	if u, err := url.Parse(input); err == nil {
+		if unescaped, err := url.PathUnescape(path.Base(u.Path)); err == nil {
+			name = unescaped
+		}
+	}
+	return []*Song{songFromProbe(probe, input, name)}, nil
+}
+
+// fileResolver plays audio files from the local disk, confined to root
+type fileResolver struct {
+	root string
+}
+
+func (fileResolver) Name() string { return "file" }
+
+func (fileResolver) Match(input string) bool {
+	return strings.HasPrefix(input, "file://") || filepath.IsAbs(input)
+}
+
+func (r fileResolver) Resolve(ctx context.Context, input string) ([]*Song, error) {
+	p, err := r.localPath(input)
+	if err != nil {
+		return nil, err
+	}
+
+	probe, err := probeAudio(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	return []*Song{songFromProbe(probe, p, name)}, nil
+}
+
+// localPath resolves input to a regular audio file inside root
+func (r fileResolver) localPath(input string) (string, error) {
+	p := filepath.Clean(strings.TrimPrefix(input, "file://"))
+
+	root, err := filepath.EvalSymlinks(r.root)
+	if err != nil {
+		return "", fmt.Errorf("music directory unavailable: %v", err)
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", p)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the music directory", p)
+	}
+
+	info, err := os.Stat(real)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file not found: %s", p)
+	}
+	if !audioExtensions[strings.ToLower(filepath.Ext(real))] {
+		return "", fmt.Errorf("%s is not a supported audio file", p)
+	}
+	return real, nil
+}
@@ -0,0 +1,62 @@
+// guild.go
+package musicbot
+
+import (
+	"log/slog"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// GuildPlayer holds the voice connection, queue and playback state for a single guild
+type GuildPlayer struct {
+	bot                  *MusicBot
+	GuildID              string
+	Session              *discordgo.Session
+	Queue                []*Song
+	QueueMutex           sync.Mutex
+	EmbedInitialized     bool
+	PlaybackMutex        sync.Mutex
+	CurrentSong          *Song
+	LoopMode             LoopMode        // Guarded by QueueMutex
+	skipVotes            map[string]bool // Users voting to skip CurrentSong, guarded by QueueMutex
+	Volume               int             // Percent (0-200), guarded by PauseState.Mutex
+	CurrentSongMessageID string          // ID of the Now Playing embed message
+	CurrentSongChannelID string          // Channel the Now Playing embed was sent to
+	log                  *slog.Logger    // Tagged with the guild ID
+	watchers             map[chan struct{}]struct{}
+	lastFrameAt          atomic.Int64 // Unix nanoseconds of the last Opus frame sent, for stall detection
+	autoPaused           atomic.Bool  // Paused because nobody was listening, so resumed when someone joins
+	loopRunning          atomic.Bool  // Set while a playQueue loop runs, so only one is started
+	idleTimer            *time.Timer  // Leaves the voice channel when it fires, guarded by idleMu
+	idleMu               sync.Mutex
+	watchersMu           sync.Mutex
+	voice                atomic.Pointer[discordgo.VoiceConnection] // nil when not in a channel; read with voiceConn
+	noticeChannelID      atomic.Pointer[string]                    // Text channel of the last command, for notices like a lost voice connection
+	PauseState           struct {
+		Paused        bool
+		Mutex         sync.Mutex
+		Pos           float64
+		TotalPlayTime float64
+		SkipReq       bool
+		SeekReq       bool
+		KillReq       bool // ffmpeg was killed on purpose, so its end isn't a dropped stream
+		SkipPending   bool // Skip asked for before ffmpeg started, turned into SkipReq by playSong
+		Cmd           *exec.Cmd
+	}
+}
+
+// newGuildPlayer constructs an idle GuildPlayer for the given guild
+func newGuildPlayer(bot *MusicBot, guildID string) *GuildPlayer {
+	return &GuildPlayer{
+		bot:     bot,
+		GuildID: guildID,
+		log:     slog.With("guild_id", guildID),
+		Session: bot.Session,
+		Queue:   make([]*Song, 0),
+		Volume:  bot.guildVolume(guildID),
+	}
+}
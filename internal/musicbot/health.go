@@ -0,0 +1,138 @@
+// health.go
+package musicbot
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthCheck is the result of checking one dependency
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the outcome of a set of checks; Healthy when every one passed
+type HealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+func newHealthReport(checks ...HealthCheck) HealthReport {
+	report := HealthReport{Healthy: true, Checks: checks}
+	for _, c := range checks {
+		report.Healthy = report.Healthy && c.OK
+	}
+	return report
+}
+
+// Liveness runs the checks a restart would fix: the gateway connection and
+// players that stopped sending audio
+func (bot *MusicBot) Liveness() HealthReport {
+	return newHealthReport(bot.checkGateway(), bot.checkPlayers())
+}
+
+// Readiness runs every check, including the slash commands and external binaries
+func (bot *MusicBot) Readiness(ctx context.Context) HealthReport {
+	checks := []HealthCheck{bot.checkGateway(), bot.checkCommands()}
+	checks = append(checks, checkBinaries(ctx)...)
+	checks = append(checks, bot.checkPlayers())
+	return newHealthReport(checks...)
+}
+
+func (bot *MusicBot) checkGateway() HealthCheck {
+	bot.Session.RLock()
+	ready := bot.Session.DataReady
+	bot.Session.RUnlock()
+
+	if !ready {
+		return HealthCheck{Name: "gateway", Detail: "not connected to the Discord gateway"}
+	}
+	return HealthCheck{Name: "gateway", OK: true, Detail: fmt.Sprintf("heartbeat latency %s", bot.Session.HeartbeatLatency().Round(time.Millisecond))}
+}
+
+func (bot *MusicBot) checkCommands() HealthCheck {
+	if !bot.commandsReady.Load() {
+		return HealthCheck{Name: "commands", Detail: "slash commands are not registered"}
+	}
+	return HealthCheck{Name: "commands", OK: true}
+}
+
+// checkPlayers fails when a guild has been playing, unpaused, without sending
+// a frame for longer than the stall timeout
+func (bot *MusicBot) checkPlayers() HealthCheck {
+	players := bot.allPlayers()
+
+	var playing int
+	var stalled []string
+	for _, gp := range players {
+		gp.PauseState.Mutex.Lock()
+		active := gp.PauseState.Cmd != nil && !gp.PauseState.Paused && !gp.PauseState.SkipReq && !gp.PauseState.SeekReq
+		gp.PauseState.Mutex.Unlock()
+		if !active {
+			continue
+		}
+
+		playing++
+		silent := time.Since(time.Unix(0, gp.lastFrameAt.Load()))
+		if silent > bot.Config.StallTimeout.Duration {
+			stalled = append(stalled, fmt.Sprintf("%s (no audio for %s)", gp.GuildID, silent.Round(time.Second)))
+		}
+	}
+
+	if len(stalled) > 0 {
+		sort.Strings(stalled)
+		return HealthCheck{Name: "players", Detail: "stalled: " + strings.Join(stalled, ", ")}
+	}
+	return HealthCheck{Name: "players", OK: true, Detail: fmt.Sprintf("%d playing", playing)}
+}
+
+// binaryCheckInterval caches the binary checks, since starting yt-dlp's
+// Python interpreter on every probe is slow
+const binaryCheckInterval = time.Minute
+
+var binaryChecks struct {
+	mu      sync.Mutex
+	at      time.Time
+	results []HealthCheck
+}
+
+// checkBinaries reports whether ffmpeg and yt-dlp run, with their versions
+func checkBinaries(ctx context.Context) []HealthCheck {
+	binaryChecks.mu.Lock()
+	defer binaryChecks.mu.Unlock()
+
+	if time.Since(binaryChecks.at) < binaryCheckInterval {
+		return binaryChecks.results
+	}
+	binaryChecks.results = []HealthCheck{
+		checkBinary(ctx, "ffmpeg", "-version"),
+		checkBinary(ctx, "yt-dlp", "--version"),
+	}
+	binaryChecks.at = time.Now()
+	return binaryChecks.results
+}
+
+func checkBinary(ctx context.Context, name string, versionArg string) HealthCheck {
+	if _, err := exec.LookPath(name); err != nil {
+		return HealthCheck{Name: name, Detail: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, versionArg).Output()
+	if err != nil {
+		return HealthCheck{Name: name, Detail: fmt.Sprintf("%s %s failed: %v", name, versionArg, err)}
+	}
+
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	// ffmpeg's first line ends with a long copyright notice
+	version, _, _ = strings.Cut(version, " Copyright")
+	return HealthCheck{Name: name, OK: true, Detail: version}
+}
@@ -0,0 +1,88 @@
+// idle.go
+package musicbot
+
+import (
+	"fmt"
+	"time"
+)
+
+// alwaysOn reports whether the guild turned on 24/7 mode, which keeps the bot
+// playing and connected with nobody listening
+func (gp *GuildPlayer) alwaysOn() bool {
+	return gp.bot.guildConfig(gp.GuildID).AlwaysOn
+}
+
+// checkListeners pauses playback when the last person leaves the bot's voice
+// channel, and resumes it when someone comes back
+func (gp *GuildPlayer) checkListeners() {
+	if gp.voiceConn() == nil || gp.alwaysOn() {
+		return
+	}
+
+	if len(gp.listeners()) == 0 {
+		gp.PauseState.Mutex.Lock()
+		playing := gp.PauseState.Cmd != nil && !gp.PauseState.Paused
+		gp.PauseState.Mutex.Unlock()
+		if playing && gp.Pause() == nil {
+			gp.autoPaused.Store(true)
+			gp.log.Info("Paused, nobody is listening")
+			gp.RefreshEmbed()
+		}
+		return
+	}
+
+	// Resume clears the flag, so a manual resume in the meantime is left alone
+	if gp.autoPaused.Load() && gp.Resume() == nil {
+		gp.log.Info("Resumed, someone is listening again")
+		gp.RefreshEmbed()
+	}
+}
+
+// idle reports whether the bot is in a voice channel with nothing to play or
+// nobody to play it to
+func (gp *GuildPlayer) idle() bool {
+	if gp.voiceConn() == nil || gp.alwaysOn() || gp.bot.shuttingDown() {
+		return false
+	}
+	if len(gp.listeners()) == 0 {
+		return true
+	}
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	return gp.CurrentSong == nil && len(gp.Queue) == 0
+}
+
+// checkIdle starts the idle timer when the bot becomes idle, and stops it when
+// it no longer is
+func (gp *GuildPlayer) checkIdle() {
+	idle := gp.idle()
+
+	gp.idleMu.Lock()
+	defer gp.idleMu.Unlock()
+	switch {
+	case idle && gp.idleTimer == nil:
+		gp.idleTimer = time.AfterFunc(gp.bot.Config.IdleTimeout.Duration, gp.leaveIdle)
+	case !idle && gp.idleTimer != nil:
+		gp.idleTimer.Stop()
+		gp.idleTimer = nil
+	}
+}
+
+// leaveIdle leaves the voice channel, keeping the queue, once the idle timeout
+// has passed, unless something happened in the meantime
+func (gp *GuildPlayer) leaveIdle() {
+	gp.idleMu.Lock()
+	gp.idleTimer = nil
+	gp.idleMu.Unlock()
+	if !gp.idle() {
+		return
+	}
+
+	// Like a lost connection, this keeps the queue so /join picks it up again
+	timeout := gp.bot.Config.IdleTimeout.Duration
+	gp.log.Info("Leaving idle voice channel", "idle_timeout", timeout)
+	gp.retireEmbed("Disconnected", fmt.Sprintf("Left the voice channel after %s without listeners or songs. Use `/join` to pick up the queue.", timeout))
+	gp.CurrentSongMessageID = ""
+	gp.CurrentSongChannelID = ""
+	gp.leaveVoice()
+}
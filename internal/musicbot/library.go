@@ -0,0 +1,561 @@
+// library.go
+package musicbot
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	libraryIndexFile = "library.json"
+	libraryCoverDir  = "covers"
+)
+
+// errNoLibrary is a /library command when no music directory is configured
+var errNoLibrary = errors.New("no music library is configured")
+
+// LibraryTrack is one indexed audio file from the music directory
+type LibraryTrack struct {
+	ID       string // Short stable hash of Path, used in autocomplete values
+	Path     string
+	Title    string
+	Artist   string
+	Album    string
+	Track    int
+	Disc     int
+	Duration float64
+	Cover    string // Extracted embedded cover art, empty if the file has none
+	Size     int64
+	ModTime  time.Time
+}
+
+// DisplayName renders the track as "Artist - Title"
+func (t *LibraryTrack) DisplayName() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + " - " + t.Title
+}
+
+// song turns the track into a Song playing straight from disk. coverURL is the
+// prefix its cover art is served under, empty when it isn't served.
+func (t *LibraryTrack) song(coverURL string) *Song {
+	duration := int(t.Duration)
+	thumbnail := defaultThumbnail
+	if t.Cover != "" && coverURL != "" {
+		thumbnail = coverURL + t.ID
+	}
+	return &Song{
+		Name:            t.DisplayName(),
+		StreamURL:       t.Path,
+		Duration:        formatDuration(duration),
+		DurationSeconds: duration,
+		Thumbnail:       thumbnail,
+		OriginalURL:     t.Path,
+		ResolvedAt:      time.Now(),
+		Source:          "file",
+		Uploader:        t.Artist,
+	}
+}
+
+// Library indexes a directory of audio files. The index is persisted as JSON in
+// the data directory so restarts only re-probe files that changed.
+type Library struct {
+	root     string
+	dataDir  string
+	coverURL string // Public URL prefix the HTTP server serves covers under, empty without one
+	mu       sync.RWMutex
+	tracks   map[string]*LibraryTrack // Keyed by Path
+	scanning sync.Mutex
+}
+
+// NewLibrary opens the library for root, loading any index saved in dataDir
+func NewLibrary(root, dataDir string) (*Library, error) {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("music directory %s is not a directory", root)
+	}
+
+	lib := &Library{
+		root:    root,
+		dataDir: dataDir,
+		tracks:  make(map[string]*LibraryTrack),
+	}
+	if err := lib.load(); err != nil {
+		slog.Warn("Could not load library index, starting fresh", "err", err)
+	}
+	return lib, nil
+}
+
+func (lib *Library) indexPath() string {
+	return filepath.Join(lib.dataDir, libraryIndexFile)
+}
+
+func (lib *Library) load() error {
+	data, err := os.ReadFile(lib.indexPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var tracks []*LibraryTrack
+	if err := json.Unmarshal(data, &tracks); err != nil {
+		return err
+	}
+
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	for _, t := range tracks {
+		lib.tracks[t.Path] = t
+	}
+	slog.Info("Loaded library index", "tracks", len(tracks), "path", lib.indexPath())
+	return nil
+}
+
+func (lib *Library) save() error {
+	lib.mu.RLock()
+	tracks := make([]*LibraryTrack, 0, len(lib.tracks))
+	for _, t := range lib.tracks {
+		tracks = append(tracks, t)
+	}
+	lib.mu.RUnlock()
+
+	data, err := json.Marshal(tracks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(lib.dataDir, 0o755); err != nil {
+		return err
+	}
+
+	// Write then rename so a crash mid-save never leaves a truncated index
+	tmp := lib.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, lib.indexPath())
+}
+
+// Scan walks the music directory, probing new or modified files and dropping
+// deleted ones, then saves the index
+func (lib *Library) Scan(ctx context.Context) error {
+	lib.scanning.Lock()
+	defer lib.scanning.Unlock()
+
+	start := time.Now()
+	slog.Info("Scanning music library", "dir", lib.root)
+
+	lib.mu.RLock()
+	previous := lib.tracks
+	lib.mu.RUnlock()
+
+	tracks := make(map[string]*LibraryTrack, len(previous))
+	probed := 0
+	err := filepath.WalkDir(lib.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Warn("Skipping library path", "path", path, "err", err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if old, ok := previous[path]; ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			tracks[path] = old
+			return nil
+		}
+
+		track, err := lib.indexFile(ctx, path, info)
+		if err != nil {
+			slog.Warn("Could not index library file", "path", path, "err", err)
+			return nil
+		}
+		tracks[path] = track
+		probed++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	lib.mu.Lock()
+	lib.tracks = tracks
+	lib.mu.Unlock()
+
+	slog.Info("Library scan finished", "duration", time.Since(start).Round(time.Millisecond), "tracks", len(tracks), "indexed", probed)
+	return lib.save()
+}
+
+// indexFile reads the tags of one file and extracts its cover art
+func (lib *Library) indexFile(ctx context.Context, path string, info fs.FileInfo) (*LibraryTrack, error) {
+	probe, err := probeAudio(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &LibraryTrack{
+		ID:       shortID(path),
+		Path:     path,
+		Title:    probe.Title,
+		Artist:   probe.Artist,
+		Album:    probe.Album,
+		Track:    probe.Track,
+		Disc:     probe.Disc,
+		Duration: probe.Duration,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if probe.HasCover {
+		cover := filepath.Join(lib.dataDir, libraryCoverDir, track.ID+".jpg")
+		if err := extractCover(ctx, path, cover); err != nil {
+			slog.Warn("Could not extract cover art", "path", path, "err", err)
+		} else {
+			track.Cover = cover
+		}
+	}
+	return track, nil
+}
+
+// extractCover writes the embedded picture of an audio file to dest as JPEG
+func extractCover(ctx context.Context, src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-v", "error", "-i", src, "-an", "-map", "0:v:0", "-frames:v", "1", dest)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// CoverPath returns the cached cover art of the track with ID id
+func (lib *Library) CoverPath(id string) (string, bool) {
+	lib.mu.RLock()
+	defer lib.mu.RUnlock()
+	for _, t := range lib.tracks {
+		if t.ID == id && t.Cover != "" {
+			return t.Cover, true
+		}
+	}
+	return "", false
+}
+
+// shortID is a short stable hash of s, used where Discord limits value length
+func shortID(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// nameID identifies an album or artist name in autocomplete values, ignoring case
+// like Tracks does, since long names would not fit Discord's 100 characters
+func nameID(name string) string {
+	return shortID(strings.ToLower(name))
+}
+
+// Len returns the number of indexed tracks
+func (lib *Library) Len() int {
+	lib.mu.RLock()
+	defer lib.mu.RUnlock()
+	return len(lib.tracks)
+}
+
+// Search returns up to limit tracks whose artist, album, title or file name
+// contain every word of query, title matches first
+func (lib *Library) Search(query string, limit int) []*LibraryTrack {
+	words := strings.Fields(strings.ToLower(query))
+
+	lib.mu.RLock()
+	var matches []*LibraryTrack
+	for _, t := range lib.tracks {
+		haystack := strings.ToLower(strings.Join([]string{t.Artist, t.Album, t.Title, filepath.Base(t.Path)}, " "))
+		if containsAll(haystack, words) {
+			matches = append(matches, t)
+		}
+	}
+	lib.mu.RUnlock()
+
+	q := strings.ToLower(query)
+	sort.Slice(matches, func(a, b int) bool {
+		ta := strings.Contains(strings.ToLower(matches[a].Title), q)
+		tb := strings.Contains(strings.ToLower(matches[b].Title), q)
+		if ta != tb {
+			return ta
+		}
+		return matches[a].DisplayName() < matches[b].DisplayName()
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func containsAll(haystack string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(haystack, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// LibraryChoice is an autocomplete suggestion: a label to show and the ID /library play receives
+type LibraryChoice struct {
+	Label string
+	Value string
+}
+
+// Complete suggests albums, artists (by nameID) or tracks (by ID) matching prefix
+func (lib *Library) Complete(kind, prefix string, limit int) []LibraryChoice {
+	words := strings.Fields(strings.ToLower(prefix))
+	seen := make(map[string]bool)
+	var choices []LibraryChoice
+
+	lib.mu.RLock()
+	for _, t := range lib.tracks {
+		var choice LibraryChoice
+		switch kind {
+		case "album":
+			if t.Album == "" {
+				continue
+			}
+			choice = LibraryChoice{Label: t.Album, Value: nameID(t.Album)}
+			if t.Artist != "" {
+				choice.Label = t.Album + " (" + t.Artist + ")"
+			}
+		case "artist":
+			if t.Artist == "" {
+				continue
+			}
+			choice = LibraryChoice{Label: t.Artist, Value: nameID(t.Artist)}
+		default:
+			choice = LibraryChoice{Label: t.DisplayName(), Value: t.ID}
+		}
+		if choice.Value == "" || seen[choice.Value] || !containsAll(strings.ToLower(choice.Label), words) {
+			continue
+		}
+		seen[choice.Value] = true
+		choices = append(choices, choice)
+	}
+	lib.mu.RUnlock()
+
+	sort.Slice(choices, func(a, b int) bool { return choices[a].Label < choices[b].Label })
+	if limit > 0 && len(choices) > limit {
+		choices = choices[:limit]
+	}
+	return choices
+}
+
+// Tracks returns the tracks of an album or artist in play order, or the single track
+// with ID value. Albums and artists match by nameID or by a name typed in full.
+func (lib *Library) Tracks(kind, value string) []*LibraryTrack {
+	lib.mu.RLock()
+	var tracks []*LibraryTrack
+	for _, t := range lib.tracks {
+		switch kind {
+		case "album":
+			if t.Album != "" && (nameID(t.Album) == value || strings.EqualFold(t.Album, value)) {
+				tracks = append(tracks, t)
+			}
+		case "artist":
+			if t.Artist != "" && (nameID(t.Artist) == value || strings.EqualFold(t.Artist, value)) {
+				tracks = append(tracks, t)
+			}
+		default:
+			if t.ID == value {
+				tracks = append(tracks, t)
+			}
+		}
+	}
+	lib.mu.RUnlock()
+
+	sort.Slice(tracks, func(a, b int) bool {
+		x, y := tracks[a], tracks[b]
+		if x.Album != y.Album {
+			return x.Album < y.Album
+		}
+		if x.Disc != y.Disc {
+			return x.Disc < y.Disc
+		}
+		if x.Track != y.Track {
+			return x.Track < y.Track
+		}
+		return x.Path < y.Path
+	})
+	return tracks
+}
+
+// librarySearchResults is how many tracks /library search lists
+const librarySearchResults = 10
+
+// librarySlash routes the /library subcommands
+func (gp *GuildPlayer) librarySlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	sub := i.ApplicationCommandData().Options[0]
+
+	lib := gp.bot.Library
+	if lib == nil {
+		respondMessage(s, i, "No music library is configured.")
+		return errNoLibrary
+	}
+
+	switch sub.Name {
+	case "search":
+		return gp.librarySearch(s, i, lib, sub.Options[0].StringValue())
+	case "play":
+		var kind, name string
+		for _, opt := range sub.Options {
+			switch opt.Name {
+			case "kind":
+				kind = opt.StringValue()
+			case "name":
+				name = opt.StringValue()
+			}
+		}
+		return gp.libraryPlay(s, i, lib, kind, name)
+	}
+	gp.interactionLog(i).Warn("Unknown library subcommand", "subcommand", sub.Name)
+	return fmt.Errorf("unknown subcommand %q", sub.Name)
+}
+
+func (gp *GuildPlayer) librarySearch(s *discordgo.Session, i *discordgo.InteractionCreate, lib *Library, query string) error {
+	tracks := lib.Search(query, librarySearchResults)
+	if len(tracks) == 0 {
+		respondMessage(s, i, fmt.Sprintf("No library tracks match **%s**.", query))
+		return errNoResults
+	}
+
+	var description string
+	for n, t := range tracks {
+		description += fmt.Sprintf("%d. %s", n+1, t.DisplayName())
+		if t.Album != "" {
+			description += fmt.Sprintf(" — *%s*", t.Album)
+		}
+		description += fmt.Sprintf(" (%s)\n", formatDuration(int(t.Duration)))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Library results for \"%s\"", query),
+		Description: description,
+		Color:       gp.bot.Config.Colors.Playing,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Queue one with /library play",
+		},
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Failed to respond to library search", "err", err)
+	}
+	return err
+}
+
+func (gp *GuildPlayer) libraryPlay(s *discordgo.Session, i *discordgo.InteractionCreate, lib *Library, kind, name string) error {
+	if err := gp.deferResponse(s, i); err != nil {
+		return err
+	}
+
+	tracks := lib.Tracks(kind, name)
+	// A track name typed without picking a suggestion is looked up by search instead of ID
+	if len(tracks) == 0 && kind == "track" {
+		tracks = lib.Search(name, 1)
+	}
+	if len(tracks) == 0 {
+		followupMessage(s, i, fmt.Sprintf("Nothing in the library matches %s **%s**.", kind, name))
+		return errNoResults
+	}
+
+	capped := false
+	if limit := gp.bot.MaxPlaylistTracks; limit > 0 && len(tracks) > limit {
+		tracks, capped = tracks[:limit], true
+	}
+
+	if err := gp.joinRequester(s, i); err != nil {
+		followupMessage(s, i, err.Error())
+		return err
+	}
+
+	songs := make([]*Song, 0, len(tracks))
+	for _, t := range tracks {
+		songs = append(songs, t.song(gp.bot.Library.coverURL))
+	}
+	res, err := gp.enqueueSongs(songs, interactionUserID(i))
+	if err != nil {
+		followupMessage(s, i, err.Error())
+		return err
+	}
+	if capped {
+		res.CappedAt, res.cappedLabel = gp.bot.MaxPlaylistTracks, "selection"
+	}
+	followupMessage(s, i, res.Message())
+	gp.startPlayback()
+	return nil
+}
+
+// handleAutocomplete suggests library albums, artists or tracks for /library play
+func (bot *MusicBot) handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	if data.Name != "library" || len(data.Options) == 0 || bot.Library == nil {
+		return
+	}
+
+	kind, typed := "track", ""
+	for _, opt := range data.Options[0].Options {
+		switch {
+		case opt.Name == "kind":
+			kind = opt.StringValue()
+		case opt.Focused:
+			typed = opt.StringValue()
+		}
+	}
+
+	// Discord allows at most 25 choices of 100 characters each
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, 25)
+	for _, c := range bot.Library.Complete(kind, typed, 25) {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncate(c.Label, 100),
+			Value: c.Value,
+		})
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		slog.Warn("Error responding to autocomplete", "guild_id", i.GuildID, "interaction_id", i.ID, "err", err)
+	}
+}
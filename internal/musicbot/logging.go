@@ -0,0 +1,134 @@
+// logging.go
+package musicbot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// LogConfig selects the log level and output format
+type LogConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error
+	Format string `json:"format"` // text or json
+}
+
+func (c LogConfig) level() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.Level))
+	return level, err
+}
+
+// SetupLogging makes a handler for cfg the default for slog and the log package
+func SetupLogging(w io.Writer, cfg LogConfig) error {
+	level, err := cfg.level()
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+
+	// discordgo has its own logger; route it through the same handler
+	discordgo.Logger = func(msgL, caller int, format string, a ...interface{}) {
+		slog.Log(context.Background(), discordgoLevels[msgL], fmt.Sprintf(format, a...), "component", "discordgo")
+	}
+	return nil
+}
+
+var discordgoLevels = map[int]slog.Level{
+	discordgo.LogError:         slog.LevelError,
+	discordgo.LogWarning:       slog.LevelWarn,
+	discordgo.LogInformational: slog.LevelInfo,
+	discordgo.LogDebug:         slog.LevelDebug,
+}
+
+// signedQueryKeys are query parameters that make a URL a credential: signed
+// stream URLs from yt-dlp, pre-signed CDN links and API keys
+var signedQueryKeys = []string{
+	"sig", "signature", "lsig", "expire", "expires", "token", "key", "policy",
+	"key-pair-id", "x-amz-signature", "x-amz-credential", "hmac", "hdnts", "auth",
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// redactURLs replaces the query string of every signed URL in s
+func redactURLs(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	return urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.RawQuery == "" {
+			return raw
+		}
+		for key := range u.Query() {
+			for _, signed := range signedQueryKeys {
+				if strings.EqualFold(key, signed) {
+					u.RawQuery = "REDACTED"
+					return u.String()
+				}
+			}
+		}
+		return raw
+	})
+}
+
+// redactAttr strips signed URLs from the message and from string and error attributes
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactURLs(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactURLs(err.Error()))
+		}
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context whose operations log through logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logFrom returns the logger stored in ctx, or the default logger
+func logFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// interactionLog is the guild's logger with the interaction and the user who sent it
+func (gp *GuildPlayer) interactionLog(i *discordgo.InteractionCreate) *slog.Logger {
+	return gp.log.With("user_id", interactionUserID(i), "interaction_id", i.ID)
+}
+
+// interactionUserID is the ID of the user who sent i, in a guild or a DM
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+// loop.go
+package musicbot
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// LoopMode controls how playQueue advances once a song finishes
+type LoopMode int
+
+const (
+	LoopOff   LoopMode = iota // Play through the queue once
+	LoopTrack                 // Repeat the current song
+	LoopQueue                 // Re-append finished songs to the tail of the queue
+)
+
+func (m LoopMode) String() string {
+	switch m {
+	case LoopTrack:
+		return "track"
+	case LoopQueue:
+		return "queue"
+	default:
+		return "off"
+	}
+}
+
+// Label is the human readable form shown in the Now Playing embed
+func (m LoopMode) Label() string {
+	switch m {
+	case LoopTrack:
+		return "🔂 Track"
+	case LoopQueue:
+		return "🔁 Queue"
+	default:
+		return "Off"
+	}
+}
+
+// next returns the mode the loop button cycles to
+func (m LoopMode) next() LoopMode {
+	return (m + 1) % 3
+}
+
+func parseLoopMode(s string) (LoopMode, error) {
+	switch s {
+	case "off":
+		return LoopOff, nil
+	case "track":
+		return LoopTrack, nil
+	case "queue":
+		return LoopQueue, nil
+	}
+	return LoopOff, fmt.Errorf("unknown loop mode %q", s)
+}
+
+// setLoopMode changes the loop mode and returns the new value
+func (gp *GuildPlayer) setLoopMode(mode LoopMode) LoopMode {
+	gp.QueueMutex.Lock()
+	gp.LoopMode = mode
+	gp.QueueMutex.Unlock()
+	gp.stateChanged()
+
+	gp.log.Info("Loop mode set", "loop", mode.String())
+	return mode
+}
+
+// cycleLoopMode advances off -> track -> queue -> off
+func (gp *GuildPlayer) cycleLoopMode() LoopMode {
+	return gp.setLoopMode(gp.loopMode().next())
+}
+
+func (gp *GuildPlayer) loopMode() LoopMode {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	return gp.LoopMode
+}
+
+// loopSlash handles /loop <off|track|queue>
+func (gp *GuildPlayer) loopSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	mode, err := parseLoopMode(i.ApplicationCommandData().Options[0].StringValue())
+	if err != nil {
+		respondMessage(s, i, fmt.Sprintf("Error: %v", err))
+		return err
+	}
+	gp.setLoopMode(mode)
+
+	respondMessage(s, i, fmt.Sprintf("Loop mode set to **%s**.", mode))
+
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		gp.updateNowPlayingEmbed(s)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// metrics.go
+package musicbot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics are written in the Prometheus text exposition format by WriteMetrics.
+// The handful of series here don't justify pulling in the client library.
+var metrics = struct {
+	ytdlpResolveSeconds  *histogramVec
+	ytdlpResolveFailures *counterVec
+	ffmpegSpawnFailures  *counter
+	framesEncoded        *counter
+	framesSent           *counter
+	encodeErrors         *counter
+	framesDropped        *counterVec
+	streamResumes        *counterVec
+	slashCommands        *counterVec
+	discordAPIErrors     *counterVec
+}{
+	ytdlpResolveSeconds: newHistogramVec("musicbot_ytdlp_resolve_seconds",
+		"Time taken by yt-dlp to resolve a song or playlist.",
+		[]float64{0.5, 1, 2, 3, 5, 8, 13, 20, 30, 60}, "op"),
+	ytdlpResolveFailures: newCounterVec("musicbot_ytdlp_resolve_failures_total",
+		"yt-dlp resolves that failed, by reason.", "op", "reason"),
+	ffmpegSpawnFailures: newCounter("musicbot_ffmpeg_spawn_failures_total",
+		"ffmpeg processes that could not be started."),
+	framesEncoded: newCounter("musicbot_opus_frames_encoded_total",
+		"Opus frames encoded from ffmpeg's PCM output."),
+	framesSent: newCounter("musicbot_opus_frames_sent_total",
+		"Opus frames handed to a Discord voice connection."),
+	encodeErrors: newCounter("musicbot_opus_encode_errors_total",
+		"PCM frames the Opus encoder rejected."),
+	framesDropped: newCounterVec("musicbot_frames_dropped_total",
+		"Audio frames read from ffmpeg but never sent, by reason (encode_error, shutdown, partial_frame or voice_timeout).", "reason"),
+	streamResumes: newCounterVec("musicbot_stream_resumes_total",
+		"Songs whose stream ended early, by outcome (resumed or gave_up).", "outcome"),
+	slashCommands: newCounterVec("musicbot_slash_commands_total",
+		"Slash command invocations, by command and outcome (ok, error, rejected, denied or unknown).", "command", "outcome"),
+	discordAPIErrors: newCounterVec("musicbot_discord_api_errors_total",
+		"Failed Discord API calls, by call.", "call"),
+}
+
+// Discord API calls counted in musicbot_discord_api_errors_total
+const (
+	callInteractionRespond = "interaction_respond"
+	callFollowup           = "followup_message"
+	callMessageEdit        = "message_edit"
+)
+
+// observeResolve records a yt-dlp resolve that started at start
+func observeResolve(op string, start time.Time, err error) {
+	metrics.ytdlpResolveSeconds.observe(time.Since(start).Seconds(), op)
+	if err == nil {
+		return
+	}
+	reason := "error"
+	switch {
+	case errors.Is(err, ErrVideoUnavailable):
+		reason = "unavailable"
+	case errors.Is(err, ErrVideoPrivate):
+		reason = "private"
+	case errors.Is(err, ErrGeoBlocked):
+		reason = "geo_blocked"
+	}
+	metrics.ytdlpResolveFailures.inc(op, reason)
+}
+
+// WriteMetrics writes every metric, plus per-guild gauges read from the players
+func (bot *MusicBot) WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	metrics.ytdlpResolveSeconds.write(bw)
+	metrics.ytdlpResolveFailures.write(bw)
+	metrics.ffmpegSpawnFailures.write(bw)
+	metrics.framesEncoded.write(bw)
+	metrics.framesSent.write(bw)
+	metrics.encodeErrors.write(bw)
+	metrics.framesDropped.write(bw)
+	metrics.streamResumes.write(bw)
+	metrics.slashCommands.write(bw)
+	metrics.discordAPIErrors.write(bw)
+
+	writeHeader(bw, "musicbot_queue_length", "Songs waiting in each guild's queue.", "gauge")
+	for _, q := range bot.queueLengths() {
+		fmt.Fprintf(bw, "musicbot_queue_length%s %d\n", formatLabels([]string{"guild"}, []string{q.guildID}), q.length)
+	}
+
+	bot.Session.RLock()
+	voice := len(bot.Session.VoiceConnections)
+	bot.Session.RUnlock()
+	writeHeader(bw, "musicbot_voice_connections", "Active Discord voice connections.", "gauge")
+	fmt.Fprintf(bw, "musicbot_voice_connections %d\n", voice)
+
+	return bw.Flush()
+}
+
+type guildQueueLength struct {
+	guildID string
+	length  int
+}
+
+// queueLengths snapshots every known player's queue length, sorted by guild
+func (bot *MusicBot) queueLengths() []guildQueueLength {
+	players := bot.allPlayers()
+
+	lengths := make([]guildQueueLength, 0, len(players))
+	for _, gp := range players {
+		gp.QueueMutex.Lock()
+		lengths = append(lengths, guildQueueLength{gp.GuildID, len(gp.Queue)})
+		gp.QueueMutex.Unlock()
+	}
+	sort.Slice(lengths, func(i, j int) bool { return lengths[i].guildID < lengths[j].guildID })
+	return lengths
+}
+
+// counter is an unlabelled counter, cheap enough to bump once per audio frame
+type counter struct {
+	name, help string
+	value      atomic.Uint64
+}
+
+func newCounter(name, help string) *counter {
+	return &counter{name: name, help: help}
+}
+
+func (c *counter) inc() { c.value.Add(1) }
+
+func (c *counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.value.Load())
+}
+
+// counterVec is a counter partitioned by label values
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*labelledValue
+}
+
+type labelledValue struct {
+	labels []string
+	count  uint64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]*labelledValue)}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &labelledValue{labels: labelValues}
+		c.values[key] = v
+	}
+	v.count++
+}
+
+func (c *counterVec) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		fmt.Fprintf(w, "%s%s %d\n", c.name, formatLabels(c.labels, v.labels), v.count)
+	}
+}
+
+// histogramVec is a histogram partitioned by label values
+type histogramVec struct {
+	name, help string
+	buckets    []float64 // Upper bounds, ascending; +Inf is implied
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, buckets: buckets, labels: labels, values: make(map[string]*histogramValue)}
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: labelValues, counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = hv
+	}
+	hv.counts[sort.SearchFloat64s(h.buckets, v)]++
+	hv.sum += v
+	hv.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, count := range hv.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			values := append(append([]string{}, hv.labels...), formatFloat(le))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), cumulative)
+		}
+		labels := formatLabels(h.labels, hv.labels)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, hv.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatLabels renders {name="value",...}, or nothing when there are no labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
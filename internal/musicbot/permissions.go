@@ -0,0 +1,166 @@
+// permissions.go
+package musicbot
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// adminPermissions let a member control playback whatever the DJ role
+const adminPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageServer
+
+// currentSongCommands act only on the current song, so its requester may use them
+// (/next checks for itself, since it falls back to a vote)
+var currentSongCommands = map[string]bool{
+	"restart": true, "pause": true, "resume": true,
+	"seek": true, "forward": true, "rewind": true,
+}
+
+// playerCommands act on everyone's songs or the voice connection and need the DJ role
+var playerCommands = map[string]bool{"stop": true, "loop": true, "volume": true, "leave": true, "move": true}
+
+// buttonCommands maps the Now Playing buttons to the command each one runs
+var buttonCommands = map[string]string{
+	"pause_button":   "pause",
+	"resume_button":  "resume",
+	"restart_button": "restart",
+	"stop_button":    "stop",
+	"loop_button":    "loop",
+}
+
+// commandOwners reports whether a command is a control command and, if so, who
+// requested the songs it affects
+func (gp *GuildPlayer) commandOwners(name string, i *discordgo.InteractionCreate) (control bool, owners []string) {
+	switch {
+	case currentSongCommands[name]:
+		return true, gp.CurrentOwners()
+	case name == "volume" && len(i.ApplicationCommandData().Options) == 0:
+		return false, nil // Only shows the volume
+	case playerCommands[name]:
+		return true, nil
+	case name == "queue":
+		sub := i.ApplicationCommandData().Options[0]
+		switch sub.Name {
+		case "show":
+			return false, nil
+		case "remove":
+			from, to, err := parseQueueRange(sub.Options[0].StringValue())
+			if err != nil {
+				return false, nil // queueSlash reports the bad range
+			}
+			owners := gp.QueueOwners(from, to)
+			return owners != nil, owners
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// authorize checks the member behind i may run a control command on owners'
+// songs, and tells them privately why not
+func (gp *GuildPlayer) authorize(s *discordgo.Session, i *discordgo.InteractionCreate, owners []string) bool {
+	if gp.memberMayControl(i, owners) {
+		return true
+	}
+
+	who := fmt.Sprintf("members with the <@&%s> role, server admins", gp.bot.guildConfig(gp.GuildID).DJRoleID)
+	if len(owners) > 0 {
+		who += " or whoever requested the song"
+	}
+	respondEphemeral(s, i, fmt.Sprintf("Only %s can do that while others are listening.", who))
+	return false
+}
+
+// memberMayControl is MayControl for the member behind i, with the permissions Discord sent
+func (gp *GuildPlayer) memberMayControl(i *discordgo.InteractionCreate, owners []string) bool {
+	return i.Member != nil && i.Member.User != nil && gp.mayControl(i.Member, i.Member.Permissions, owners)
+}
+
+// CurrentOwners returns who requested the current song, for MayControl
+func (gp *GuildPlayer) CurrentOwners() []string {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	if gp.CurrentSong == nil {
+		return nil
+	}
+	return []string{gp.CurrentSong.RequesterID}
+}
+
+// QueueOwners returns who requested the songs at 1-based positions from through
+// to, or nil when the range is out of bounds
+func (gp *GuildPlayer) QueueOwners(from, to int) []string {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	if gp.checkPosition(from) != nil || gp.checkPosition(to) != nil {
+		return nil
+	}
+	owners := make([]string, 0, to-from+1)
+	for _, song := range gp.Queue[from-1 : to] {
+		owners = append(owners, song.RequesterID)
+	}
+	return owners
+}
+
+// MayControl reports whether userID may run a control command that affects the
+// songs requested by owners. Anyone may when the guild has no DJ role; otherwise
+// DJs, server admins, someone alone with the bot, or the requester of every
+// affected song may.
+func (gp *GuildPlayer) MayControl(userID string, owners ...string) bool {
+	var perms int64
+	if channelID := gp.voiceChannelID(); channelID != "" {
+		perms, _ = gp.Session.State.UserChannelPermissions(userID, channelID)
+	}
+	member, _ := gp.Session.State.Member(gp.GuildID, userID)
+	if member == nil {
+		member = &discordgo.Member{User: &discordgo.User{ID: userID}}
+	}
+	return gp.mayControl(member, perms, owners)
+}
+
+func (gp *GuildPlayer) mayControl(member *discordgo.Member, perms int64, owners []string) bool {
+	djRole := gp.bot.guildConfig(gp.GuildID).DJRoleID
+	switch {
+	case djRole == "":
+		return true
+	case perms&adminPermissions != 0:
+		return true
+	case slices.Contains(member.Roles, djRole):
+		return true
+	case gp.aloneWith(member.User.ID):
+		return true
+	}
+	if len(owners) == 0 {
+		return false
+	}
+	for _, owner := range owners {
+		if owner != member.User.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// aloneWith reports whether userID is the only person listening with the bot
+func (gp *GuildPlayer) aloneWith(userID string) bool {
+	listeners := gp.listeners()
+	return len(listeners) == 1 && listeners[userID]
+}
+
+// isBot reports whether a voice state belongs to a bot, including this one;
+// the caller must hold the state lock
+func (gp *GuildPlayer) isBot(guild *discordgo.Guild, vs *discordgo.VoiceState) bool {
+	if vs.Member != nil && vs.Member.User != nil {
+		return vs.Member.User.Bot
+	}
+	if gp.Session.State.User != nil && vs.UserID == gp.Session.State.User.ID {
+		return true
+	}
+	for _, m := range guild.Members {
+		if m.User != nil && m.User.ID == vs.UserID {
+			return m.User.Bot
+		}
+	}
+	return false
+}
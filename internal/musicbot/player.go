@@ -1,160 +1,386 @@
 package musicbot
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-// handlePlayCommand joins the voice channel, fetches song info, appends to queue
-func (bot *MusicBot) handlePlayCommandSlash(s *discordgo.Session, i *discordgo.InteractionCreate, url string) {
+// handlePlayCommand resolves the /play options, then joins the voice channel and queues the song
+func (gp *GuildPlayer) handlePlayCommandSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 	})
 	if err != nil {
-		log.Printf("Error acknowledging interaction: %v", err)
-		return
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error acknowledging interaction", "err", err)
+		return err
 	}
 
-	log.Printf("handlePlayCommand called with URL: %s", url)
+	var url, query string
+	var opts PlaylistOptions
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "url":
+			url = strings.TrimSpace(opt.StringValue())
+		case "query":
+			query = strings.TrimSpace(opt.StringValue())
+		case "shuffle":
+			opts.Shuffle = opt.BoolValue()
+		case "offset":
+			opts.Offset = int(opt.IntValue())
+		case "limit":
+			opts.Limit = int(opt.IntValue())
+		}
+	}
 
-	// Join the user's voice channel
-	log.Println("Attempting to join voice channel...")
-	vc, err := bot.joinVoiceChannelSlash(s, i)
+	// An explicit query lets the user pick from the top results
+	if query != "" {
+		return gp.sendSearchPicker(s, i, query)
+	}
+	if url == "" {
+		followupMessage(s, i, "Give me a `url` to play or a `query` to search for.")
+		return errors.New("no url or query")
+	}
+
+	ctx := withLogger(gp.bot.ctx, gp.interactionLog(i))
+	logFrom(ctx).Info("Play requested", "input", url)
+
+	// Anything no source recognises is treated as a search for its first hit
+	url, err = gp.searchFallback(ctx, url)
 	if err != nil {
-		log.Printf("Error joining voice channel: %v", err)
-		_, followErr := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: fmt.Sprintf("Error joining voice channel: %v", err),
-		})
-		if followErr != nil {
-			log.Printf("Error sending follow-up message: %v", followErr)
-		}
-		return
+		followupMessage(s, i, err.Error())
+		return err
 	}
-	bot.VoiceConn = vc
-	log.Println("Successfully joined voice channel.")
 
-	// Fetch song info
-	song, err := safeFetchSongInfo(url)
+	res, err := gp.enqueueURL(s, i, url, opts)
 	if err != nil {
-		log.Printf("Error fetching song info: %v", err)
-		_, followErr := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: fmt.Sprintf("Error fetching song info: %v", err),
-		})
-		if followErr != nil {
-			log.Printf("Error sending follow-up message: %v", followErr)
-		}
-		return
+		followupMessage(s, i, err.Error())
+		return err
 	}
+	followupMessage(s, i, res.Message())
+	gp.startPlayback()
+	return nil
+}
 
-	// Add to the queue
-	bot.QueueMutex.Lock()
-	bot.Queue = append(bot.Queue, song)
-	bot.QueueMutex.Unlock()
-	log.Printf("Added song to queue: %s", song.Name)
+// enqueueURL joins the requester's voice channel, fetches the song or playlist at url and
+// appends it to the queue. The returned error is already phrased for the user.
+func (gp *GuildPlayer) enqueueURL(s *discordgo.Session, i *discordgo.InteractionCreate, url string, opts PlaylistOptions) (EnqueueResult, error) {
+	if err := gp.joinRequester(s, i); err != nil {
+		return EnqueueResult{}, err
+	}
+	return gp.enqueueInput(withLogger(gp.bot.ctx, gp.interactionLog(i)), url, opts, interactionUserID(i))
+}
 
-	_, followErr := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-		Content: fmt.Sprintf("Added **%s** to the queue.", song.Name),
-	})
-	if followErr != nil {
-		log.Printf("Error sending follow-up message: %v", followErr)
+// enqueueInput fetches the song or playlist at url and appends it to the queue on
+// behalf of requesterID. The returned error is already phrased for the user.
+func (gp *GuildPlayer) enqueueInput(ctx context.Context, url string, opts PlaylistOptions, requesterID string) (EnqueueResult, error) {
+	songs, capped, err := gp.fetchSongs(ctx, url, opts)
+	if err != nil {
+		logFrom(ctx).Error("Error fetching song info", "song_url", url, "err", err)
+		return EnqueueResult{}, fmt.Errorf("Error fetching song info: %v", err)
+	}
+
+	res, err := gp.enqueueSongs(songs, requesterID)
+	if capped {
+		res.CappedAt, res.cappedLabel = gp.bot.MaxPlaylistTracks, "playlist"
+	}
+	return res, err
+}
+
+// joinRequester joins the voice channel of the user behind the interaction, reusing
+// the connection when the bot is already there or the user isn't in voice.
+// The returned error is already phrased for the user.
+func (gp *GuildPlayer) joinRequester(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	channelID := ""
+	if vs, err := s.State.VoiceState(i.GuildID, interactionUserID(i)); err == nil {
+		channelID = vs.ChannelID
+	}
+	if gp.voiceConn() != nil && (channelID == "" || channelID == gp.voiceChannelID()) {
+		return nil
+	}
+	if channelID == "" {
+		return errors.New("Join a voice channel first.")
+	}
+
+	if err := gp.joinFor(channelID); err != nil {
+		gp.interactionLog(i).Warn("Error joining voice channel", "channel_id", channelID, "err", err)
+		return fmt.Errorf("Error joining voice channel: %v", err)
+	}
+	gp.interactionLog(i).Info("Joined voice channel", "channel_id", channelID)
+	return nil
+}
+
+// enqueueSongs appends as many songs as the guild's max queue length allows, marking
+// them as requested by requesterID. The caller replies, then calls startPlayback.
+// The returned error is already phrased for the user.
+func (gp *GuildPlayer) enqueueSongs(songs []*Song, requesterID string) (EnqueueResult, error) {
+	cfg := gp.bot.guildConfig(gp.GuildID)
+	limit := cfg.MaxQueueLength
+	res := EnqueueResult{Songs: songs}
+	for _, song := range songs {
+		song.RequesterID = requesterID
 	}
 
-	// Start playback if not already playing
-	bot.PlaybackMutex.Lock()
-	if !bot.CurrentlyPlaying {
-		log.Println("Starting playback as no song is currently playing.")
-		bot.CurrentlyPlaying = true
-		bot.PlaybackMutex.Unlock()
-		go bot.playQueue()
+	gp.QueueMutex.Lock()
+	if limit > 0 {
+		room := limit - len(gp.Queue)
+		if room <= 0 {
+			gp.QueueMutex.Unlock()
+			return EnqueueResult{}, fmt.Errorf("%w (%d songs), try again once some have played", ErrQueueFull, limit)
+		}
+		if len(songs) > room {
+			res.Songs, res.QueueLimit = songs[:room], limit
+		}
+	}
+	res.Songs, res.UserLimit = gp.userLimit(res.Songs, requesterID, cfg)
+	if len(res.Songs) == 0 {
+		gp.QueueMutex.Unlock()
+		return EnqueueResult{}, fmt.Errorf("%w: %s", ErrUserLimit, res.UserLimit)
+	}
+	if cfg.FairQueue {
+		gp.fairInsert(res.Songs)
 	} else {
-		log.Println("Playback already in progress, song added to the queue.")
-		bot.PlaybackMutex.Unlock()
+		gp.Queue = append(gp.Queue, res.Songs...)
 	}
+	gp.QueueMutex.Unlock()
+	gp.stateChanged()
+	gp.log.Info("Added songs to queue", "count", len(res.Songs))
+	return res, nil
 }
 
-// joinVoiceChannel finds which voice channel the user is in and joins it
-func (bot *MusicBot) joinVoiceChannelSlash(s *discordgo.Session, i *discordgo.InteractionCreate) (*discordgo.VoiceConnection, error) {
-	guildID := i.GuildID
-	userID := i.Member.User.ID
+// fetchSongs resolves url to the songs to enqueue: the selected entries of a playlist, or the single song
+func (gp *GuildPlayer) fetchSongs(ctx context.Context, url string, opts PlaylistOptions) (songs []*Song, capped bool, err error) {
+	songs, err = gp.bot.Resolvers.Resolve(ctx, url)
+	if err != nil {
+		return nil, false, err
+	}
+	// A playlist with a single entry gets the same range as a longer one
+	if len(songs) > 0 && opts.Offset >= len(songs) {
+		return nil, false, fmt.Errorf("offset %d is past the end of the playlist (%d entries)", opts.Offset, len(songs))
+	}
+	songs, capped = opts.apply(songs, gp.bot.MaxPlaylistTracks)
+	if len(songs) == 0 {
+		return nil, false, fmt.Errorf("no playable entries in the playlist")
+	}
+	return songs, capped, nil
+}
 
-	// Get the user's voice state to find their current voice channel
-	vs, err := s.State.VoiceState(guildID, userID)
+// startPlayback starts playQueue unless it is already running
+func (gp *GuildPlayer) startPlayback() {
+	if gp.loopRunning.CompareAndSwap(false, true) {
+		go gp.playQueue()
+	}
+	gp.checkIdle()
+}
+
+// followupMessage sends a follow-up to a deferred interaction
+func followupMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not find user's voice state: %v", err)
+		metrics.discordAPIErrors.inc(callFollowup)
+		slog.Warn("Error sending follow-up message", "guild_id", i.GuildID, "interaction_id", i.ID, "err", err)
 	}
+}
+
+// joinChannel connects to a voice channel in this guild, moving if already connected
+// elsewhere. The current song is paused across a move so it carries on where it was.
+func (gp *GuildPlayer) joinChannel(channelID string) error {
+	if gp.voiceChannelID() == channelID {
+		return nil
+	}
+
+	gp.PauseState.Mutex.Lock()
+	playing := gp.PauseState.Cmd != nil && !gp.PauseState.Paused
+	gp.PauseState.Mutex.Unlock()
+	paused := playing && gp.Pause() == nil
 
-	// Join the user's voice channel
-	vc, err := s.ChannelVoiceJoin(vs.GuildID, vs.ChannelID, false, true)
+	vc, err := gp.Session.ChannelVoiceJoin(gp.GuildID, channelID, false, true)
+	if err == nil {
+		gp.setVoiceConn(vc)
+		gp.stateChanged()
+	}
+	if paused {
+		_ = gp.Resume()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to join voice channel: %v", err)
+		return fmt.Errorf("failed to join voice channel: %v", err)
 	}
+	return nil
+}
 
-	return vc, nil
+// advanceQueue decides what plays after song ends, according to the loop mode
+func (gp *GuildPlayer) advanceQueue(song *Song, interrupted bool) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	// The song was replaced or cleared (e.g. by /stop) while it was playing
+	if gp.CurrentSong != song {
+		return
+	}
+
+	switch {
+	case gp.LoopMode == LoopTrack && !interrupted:
+		// Keep CurrentSong and replay it from the top
+		gp.PauseState.Mutex.Lock()
+		gp.PauseState.Pos = 0
+		gp.PauseState.TotalPlayTime = 0
+		gp.PauseState.Mutex.Unlock()
+		return
+	case gp.LoopMode == LoopQueue:
+		gp.Queue = append(gp.Queue, song)
+	}
+	gp.CurrentSong = nil
 }
 
 // playQueue handles iterating through the queue, playing each song
-func (bot *MusicBot) playQueue() {
-	log.Println("playQueue called")
+func (gp *GuildPlayer) playQueue() {
+	gp.log.Debug("Playback loop started")
 
-	for {
-		bot.QueueMutex.Lock()
+	streamRetries := 0 // Times the current song was resumed after its stream ended early
+	// Leaving voice ends the loop; the queue stays for the next /join
+	for !gp.bot.shuttingDown() && gp.voiceConn() != nil {
+		gp.QueueMutex.Lock()
 		// If no songs left and no current song, we're done
-		if len(bot.Queue) == 0 && bot.CurrentSong == nil {
-			bot.QueueMutex.Unlock()
-			log.Println("Queue is empty and no song is currently playing. Stopping playback.")
+		if len(gp.Queue) == 0 && gp.CurrentSong == nil {
+			gp.QueueMutex.Unlock()
 			break
 		}
 
-		// If there's no current song, pop from the queue
+		// If there's no current song, pop from the queue and start it from the top
 		var song *Song
-		if bot.CurrentSong == nil {
-			song = bot.Queue[0]
-			bot.Queue = bot.Queue[1:]
-			bot.CurrentSong = song
+		fresh := gp.CurrentSong == nil
+		if fresh {
+			song = gp.Queue[0]
+			gp.Queue = gp.Queue[1:]
+			gp.CurrentSong = song
+			gp.resetSkipVotes()
+			streamRetries = 0
+
+			gp.PauseState.Mutex.Lock()
+			gp.PauseState.Pos = 0
+			gp.PauseState.TotalPlayTime = 0
+			gp.PauseState.Mutex.Unlock()
 		} else {
-			song = bot.CurrentSong
+			song = gp.CurrentSong
 		}
-		bot.QueueMutex.Unlock()
+		gp.QueueMutex.Unlock()
 
-		log.Printf("Playing song: %+v", song)
+		lg := gp.log.With("song_url", song.OriginalURL)
+		if err := gp.bot.Resolvers.Refresh(withLogger(context.Background(), lg), song, &gp.QueueMutex); err != nil {
+			lg.Error("Error refreshing stream URL", "song", song.Name, "err", err)
+			if song.StreamURL == "" {
+				// Lazily queued playlist entry that can't be resolved; drop it rather than loop it
+				gp.QueueMutex.Lock()
+				if gp.CurrentSong == song {
+					gp.CurrentSong = nil
+				}
+				gp.QueueMutex.Unlock()
+				// A skip meant for this song must not carry over to the next one
+				gp.PauseState.Mutex.Lock()
+				gp.PauseState.SkipPending = false
+				gp.PauseState.Mutex.Unlock()
+				continue
+			}
+		}
+
+		if fresh {
+			gp.stateChanged()
+			gp.announceSong(song)
+		}
+		lg.Info("Playing song", "song", song.Name, "source", song.Source)
 
 		// Actually play the song
-		bot.PlaybackMutex.Lock()
-		err := bot.playSong(song)
-		bot.PlaybackMutex.Unlock()
+		gp.PlaybackMutex.Lock()
+		err := gp.playSong(song)
+		gp.PlaybackMutex.Unlock()
 
-		if err != nil {
-			log.Printf("Error playing song: %v", err)
+		voiceLost := errors.Is(err, errVoiceLost)
+		if err != nil && !voiceLost {
+			lg.Error("Error playing song", "err", err)
+		}
+		if gp.bot.shuttingDown() {
+			// Keep the current song and position so they are saved for the next start
+			break
+		}
+		if gp.voiceConn() == nil {
+			// Left voice; keep the current song and position for the next /join
+			break
+		}
+		if voiceLost {
+			if err := gp.reconnectVoice(); err != nil {
+				gp.giveUpVoice(err)
+				break
+			}
 		}
 
 		// Handle skipping or finishing
-		bot.PauseState.Mutex.Lock()
-		if bot.PauseState.SkipReq {
-			// Reset skip state
-			bot.PauseState.SkipReq = false
-			bot.PauseState.Paused = false
-			bot.PauseState.Pos = 0
-			bot.PauseState.TotalPlayTime = 0
-			bot.CurrentSong = nil
-		} else if !bot.PauseState.Paused {
-			// Song finished naturally, move to the next one
-			bot.CurrentSong = nil
+		// A skip wins over a seek requested while the same ffmpeg was being killed;
+		// the flags are cleared together so the next playSong doesn't see a stale request
+		gp.PauseState.Mutex.Lock()
+		skipped := gp.PauseState.SkipReq
+		seeked := gp.PauseState.SeekReq && !skipped
+		killed := gp.PauseState.KillReq
+		paused := gp.PauseState.Paused
+		gp.PauseState.SeekReq = false
+		gp.PauseState.SkipReq = false
+		gp.PauseState.KillReq = false
+		if skipped {
+			gp.PauseState.Paused = false
+			gp.PauseState.Pos = 0
+			gp.PauseState.TotalPlayTime = 0
+		}
+		gp.PauseState.Mutex.Unlock()
+
+		// A stream that ends well before the song does dropped or its URL expired;
+		// one we killed ourselves only looks that way
+		if err == nil && !killed && gp.endedEarly(song) {
+			if streamRetries < gp.bot.Config.StreamRetries {
+				streamRetries++
+				gp.resumeStream(lg, song, streamRetries)
+				continue
+			}
+			metrics.streamResumes.inc("gave_up")
+			lg.Warn("Stream ended early too often, moving on", "song", song.Name, "attempts", streamRetries)
+		}
+
+		// After a reconnect the current song carries on from where the voice dropped
+		if !seeked && (skipped || (!paused && !voiceLost)) {
+			// A failed song counts as interrupted so repeat-one doesn't retry it forever
+			gp.advanceQueue(song, skipped || err != nil)
+			streamRetries = 0
 		}
-		bot.PauseState.Mutex.Unlock()
 
 		// Wait while paused
-		for bot.PauseState.Paused {
-			log.Println("Playback is paused. Waiting to resume...")
+		for gp.PauseState.Paused && !gp.bot.shuttingDown() {
 			time.Sleep(500 * time.Millisecond)
 		}
 	}
 
-	bot.PlaybackMutex.Lock()
-	bot.CurrentlyPlaying = false
-	bot.PlaybackMutex.Unlock()
+	gp.loopRunning.Store(false)
+	gp.stateChanged()
+	gp.checkIdle()
+	gp.log.Info("Playback loop finished")
 
-	log.Println("Playback finished for all songs in the queue.")
+	// Songs queued while the loop was finishing saw it still running, so start them now
+	if gp.readyToPlay() {
+		gp.startPlayback()
+	}
+}
+
+// readyToPlay reports whether the bot is in voice with something to play
+func (gp *GuildPlayer) readyToPlay() bool {
+	if gp.voiceConn() == nil || gp.bot.shuttingDown() {
+		return false
+	}
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	return gp.CurrentSong != nil || len(gp.Queue) > 0
 }
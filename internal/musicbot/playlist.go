@@ -0,0 +1,82 @@
+// playlist.go
+package musicbot
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// DefaultMaxPlaylistTracks caps how many entries a single playlist import may enqueue
+const DefaultMaxPlaylistTracks = 100
+
+// minPlaylistOffset and minPlaylistLimit bound the /play playlist options
+var (
+	minPlaylistOffset = 0.0
+	minPlaylistLimit  = 1.0
+)
+
+// PlaylistOptions narrows down which playlist entries /play enqueues
+type PlaylistOptions struct {
+	Shuffle bool
+	Offset  int // Entries to skip from the start of the playlist
+	Limit   int // Maximum entries to enqueue, 0 for no limit beyond the cap
+}
+
+// apply offsets, shuffles and limits the entries, then enforces maxTracks.
+// capped reports whether maxTracks cut the selection short.
+func (o PlaylistOptions) apply(songs []*Song, maxTracks int) (selected []*Song, capped bool) {
+	if o.Offset >= len(songs) {
+		return nil, false
+	}
+	selected = append([]*Song(nil), songs[o.Offset:]...)
+
+	if o.Shuffle {
+		rand.Shuffle(len(selected), func(a, b int) {
+			selected[a], selected[b] = selected[b], selected[a]
+		})
+	}
+	if o.Limit > 0 && o.Limit < len(selected) {
+		selected = selected[:o.Limit]
+	}
+	if maxTracks > 0 && len(selected) > maxTracks {
+		return selected[:maxTracks], true
+	}
+	return selected, false
+}
+
+// EnqueueResult is what an enqueue added, and why it may have added less than requested
+type EnqueueResult struct {
+	Songs       []*Song
+	CappedAt    int    // Track cap that cut a playlist or selection short, 0 if none did
+	QueueLimit  int    // Max queue length that cut the request short, 0 if it didn't
+	UserLimit   string // Why the per-user limits cut the request short, empty if they didn't
+	cappedLabel string
+}
+
+// Message tells the requester what was added
+func (r EnqueueResult) Message() string {
+	msg := describeAdded(r.Songs)
+	if r.CappedAt > 0 {
+		msg += fmt.Sprintf(" The %s was capped at %d tracks.", r.cappedLabel, r.CappedAt)
+	}
+	if r.QueueLimit > 0 {
+		msg += fmt.Sprintf(" The queue is limited to %d songs, so the rest were left out.", r.QueueLimit)
+	}
+	if r.UserLimit != "" {
+		msg += fmt.Sprintf(" The rest were left out, %s.", r.UserLimit)
+	}
+	return msg
+}
+
+// describeAdded summarizes what an enqueue added, e.g. "Added 12 tracks (45:10)"
+func describeAdded(songs []*Song) string {
+	if len(songs) == 1 {
+		return fmt.Sprintf("Added **%s** to the queue.", songs[0].Name)
+	}
+
+	total := 0
+	for _, song := range songs {
+		total += song.DurationSeconds
+	}
+	return fmt.Sprintf("Added %d tracks (%s) to the queue.", len(songs), formatDuration(total))
+}
@@ -0,0 +1,195 @@
+// queue.go
+package musicbot
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// minQueuePosition is the lower bound for 1-based queue position options
+var minQueuePosition = 1.0
+
+// queueSlash routes the /queue subcommands
+func (gp *GuildPlayer) queueSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	sub := i.ApplicationCommandData().Options[0]
+
+	var msg string
+	var err error
+	switch sub.Name {
+	case "show":
+		return gp.listQueueSlash(s, i)
+	case "remove":
+		msg, err = gp.removeFromQueue(sub.Options[0].StringValue())
+	case "move":
+		msg, err = gp.MoveInQueue(int(sub.Options[0].IntValue()), int(sub.Options[1].IntValue()))
+	case "swap":
+		msg, err = gp.swapInQueue(int(sub.Options[0].IntValue()), int(sub.Options[1].IntValue()))
+	case "clear":
+		msg, err = gp.clearQueue()
+	case "shuffle":
+		msg, err = gp.shuffleQueue()
+	case "skipto":
+		msg, err = gp.skipTo(int(sub.Options[0].IntValue()))
+	default:
+		gp.interactionLog(i).Warn("Unknown queue subcommand", "subcommand", sub.Name)
+		return fmt.Errorf("unknown subcommand %q", sub.Name)
+	}
+
+	if err != nil {
+		respondMessage(s, i, fmt.Sprintf("Error: %v", err))
+		return err
+	}
+	return gp.respondQueue(s, i, msg)
+}
+
+// parseQueueRange parses a 1-based position ("3") or inclusive range ("2-5")
+func parseQueueRange(input string) (from, to int, err error) {
+	input = strings.TrimSpace(input)
+	first, last, isRange := strings.Cut(input, "-")
+
+	from, err = strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid position %q", input)
+	}
+	to = from
+	if isRange {
+		to, err = strconv.Atoi(strings.TrimSpace(last))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid range %q", input)
+		}
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return from, to, nil
+}
+
+// checkPosition validates a 1-based queue position; the caller must hold QueueMutex
+func (gp *GuildPlayer) checkPosition(pos int) error {
+	if pos < 1 || pos > len(gp.Queue) {
+		return fmt.Errorf("position %d is out of range (queue has %d songs)", pos, len(gp.Queue))
+	}
+	return nil
+}
+
+func (gp *GuildPlayer) removeFromQueue(input string) (string, error) {
+	from, to, err := parseQueueRange(input)
+	if err != nil {
+		return "", err
+	}
+	return gp.RemoveFromQueue(from, to)
+}
+
+// RemoveFromQueue drops the songs at 1-based positions from through to
+func (gp *GuildPlayer) RemoveFromQueue(from, to int) (string, error) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	if err := gp.checkPosition(from); err != nil {
+		return "", err
+	}
+	if err := gp.checkPosition(to); err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("Removed %d songs from the queue.", to-from+1)
+	if from == to {
+		msg = fmt.Sprintf("Removed **%s** from the queue.", gp.Queue[from-1].Name)
+	}
+	gp.Queue = append(gp.Queue[:from-1], gp.Queue[to:]...)
+	gp.stateChanged()
+	return msg, nil
+}
+
+// MoveInQueue moves the song at 1-based position from to position to
+func (gp *GuildPlayer) MoveInQueue(from, to int) (string, error) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	if err := gp.checkPosition(from); err != nil {
+		return "", err
+	}
+	if err := gp.checkPosition(to); err != nil {
+		return "", err
+	}
+
+	song := gp.Queue[from-1]
+	gp.Queue = append(gp.Queue[:from-1], gp.Queue[from:]...)
+	gp.Queue = append(gp.Queue[:to-1], append([]*Song{song}, gp.Queue[to-1:]...)...)
+	gp.stateChanged()
+	return fmt.Sprintf("Moved **%s** to position %d.", song.Name, to), nil
+}
+
+func (gp *GuildPlayer) swapInQueue(a, b int) (string, error) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	if err := gp.checkPosition(a); err != nil {
+		return "", err
+	}
+	if err := gp.checkPosition(b); err != nil {
+		return "", err
+	}
+
+	gp.Queue[a-1], gp.Queue[b-1] = gp.Queue[b-1], gp.Queue[a-1]
+	gp.stateChanged()
+	return fmt.Sprintf("Swapped positions %d and %d.", a, b), nil
+}
+
+// clearQueue drops every upcoming song but keeps the current one playing
+func (gp *GuildPlayer) clearQueue() (string, error) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	cleared := len(gp.Queue)
+	gp.Queue = nil
+	gp.stateChanged()
+	return fmt.Sprintf("Cleared %d songs from the queue.", cleared), nil
+}
+
+func (gp *GuildPlayer) shuffleQueue() (string, error) {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+
+	if len(gp.Queue) < 2 {
+		return "", fmt.Errorf("not enough songs in the queue to shuffle")
+	}
+	rand.Shuffle(len(gp.Queue), func(a, b int) {
+		gp.Queue[a], gp.Queue[b] = gp.Queue[b], gp.Queue[a]
+	})
+	gp.stateChanged()
+	return "Shuffled the queue.", nil
+}
+
+// skipTo drops the songs before pos and skips the current one so pos plays next
+func (gp *GuildPlayer) skipTo(pos int) (string, error) {
+	gp.QueueMutex.Lock()
+	if err := gp.checkPosition(pos); err != nil {
+		gp.QueueMutex.Unlock()
+		return "", err
+	}
+
+	skipped := gp.Queue[: pos-1 : pos-1]
+	gp.Queue = gp.Queue[pos-1:]
+	if gp.LoopMode == LoopQueue {
+		// In queue loop nothing is dropped, the skipped songs just go round again
+		gp.Queue = append(gp.Queue, skipped...)
+	}
+	target := gp.Queue[0]
+	gp.QueueMutex.Unlock()
+	gp.stateChanged()
+
+	gp.PauseState.Mutex.Lock()
+	if gp.PauseState.Cmd != nil {
+		gp.PauseState.SkipReq = true
+		gp.PauseState.KillReq = true
+		_ = gp.PauseState.Cmd.Process.Kill()
+	}
+	gp.PauseState.Mutex.Unlock()
+
+	return fmt.Sprintf("Skipping to **%s**.", target.Name), nil
+}
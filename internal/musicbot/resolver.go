@@ -0,0 +1,213 @@
+// resolver.go
+package musicbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver turns user input (a URL, path, ...) into playable songs
+type Resolver interface {
+	// Name identifies the backend in configuration and in Song.Source
+	Name() string
+	// Match reports whether this backend can handle input
+	Match(input string) bool
+	// Resolve returns one song, or several for playlists. Songs may be returned
+	// without a StreamURL; the registry resolves those just before playback.
+	Resolve(ctx context.Context, input string) ([]*Song, error)
+}
+
+// singleResolver is implemented by backends that can re-resolve one song without
+// expanding the playlist its URL may belong to
+type singleResolver interface {
+	ResolveSong(ctx context.Context, input string) (*Song, error)
+}
+
+// expiringResolver is implemented by backends whose stream URLs stop working after a while
+type expiringResolver interface {
+	StreamURLMaxAge() time.Duration
+}
+
+// ResolverOptions carries the deployment settings resolver backends may need
+type ResolverOptions struct {
+	MusicDir    string // Root directory the file backend may read from
+	AudioFormat string // yt-dlp format selector
+}
+
+// ResolverFactory builds a backend from the deployment options. It returns nil
+// when the backend isn't usable with those options (e.g. no music directory).
+type ResolverFactory func(opts ResolverOptions) Resolver
+
+var resolverFactories = map[string]ResolverFactory{
+	"ytdlp": func(opts ResolverOptions) Resolver {
+		format := opts.AudioFormat
+		if format == "" {
+			format = "bestaudio"
+		}
+		return ytdlpResolver{format: format}
+	},
+	"http": func(ResolverOptions) Resolver { return httpResolver{} },
+	"file": func(opts ResolverOptions) Resolver {
+		if opts.MusicDir == "" {
+			return nil
+		}
+		return fileResolver{root: opts.MusicDir}
+	},
+}
+
+// DefaultResolverOrder tries local files, then direct audio links, then anything yt-dlp supports
+var DefaultResolverOrder = []string{"file", "http", "ytdlp"}
+
+// RegisterResolver makes a backend available to NewResolverRegistry under name
+func RegisterResolver(name string, factory ResolverFactory) {
+	resolverFactories[name] = factory
+}
+
+// ResolverRegistry picks the first backend, in configured order, that matches the input
+type ResolverRegistry struct {
+	resolvers []Resolver
+	byName    map[string]Resolver
+}
+
+// NewResolverRegistry builds the backends listed in order
+func NewResolverRegistry(order []string, opts ResolverOptions) (*ResolverRegistry, error) {
+	reg := &ResolverRegistry{byName: make(map[string]Resolver)}
+	for _, name := range order {
+		name = strings.TrimSpace(name)
+		factory, ok := resolverFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown resolver %q", name)
+		}
+		r := factory(opts)
+		if r == nil {
+			slog.Info("Resolver is not configured, skipping it", "resolver", name)
+			continue
+		}
+		reg.resolvers = append(reg.resolvers, r)
+		reg.byName[name] = r
+	}
+	if len(reg.resolvers) == 0 {
+		return nil, fmt.Errorf("no resolvers configured")
+	}
+	return reg, nil
+}
+
+// Match reports whether any backend can handle input
+func (reg *ResolverRegistry) Match(input string) bool {
+	return reg.find(input) != nil
+}
+
+func (reg *ResolverRegistry) find(input string) Resolver {
+	for _, r := range reg.resolvers {
+		if r.Match(input) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Resolve hands input to the first matching backend and tags the songs with its name
+func (reg *ResolverRegistry) Resolve(ctx context.Context, input string) ([]*Song, error) {
+	r := reg.find(input)
+	if r == nil {
+		return nil, fmt.Errorf("no source can play %q", input)
+	}
+	return resolveWith(r, func() ([]*Song, error) {
+		return r.Resolve(ctx, input)
+	})
+}
+
+// resolveWith runs a backend call, tagging its songs and turning a panic in it into an error
+func resolveWith(r Resolver, resolve func() ([]*Song, error)) (songs []*Song, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("Panic in resolver", "resolver", r.Name(), "panic", rec)
+			songs, err = nil, fmt.Errorf("could not fetch song information")
+		}
+	}()
+
+	songs, err = resolve()
+	if err != nil {
+		return nil, err
+	}
+	for _, song := range songs {
+		song.Source = r.Name()
+	}
+	return songs, nil
+}
+
+// Fresh resolves song.OriginalURL again with the backend that produced it
+func (reg *ResolverRegistry) Fresh(ctx context.Context, song *Song) (*Song, error) {
+	r, ok := reg.byName[song.Source]
+	if !ok {
+		// Unknown or unconfigured source, fall back to matching the URL
+		r = reg.find(song.OriginalURL)
+	}
+	if r == nil {
+		return nil, fmt.Errorf("no source can play %q", song.OriginalURL)
+	}
+
+	songs, err := resolveWith(r, func() ([]*Song, error) {
+		if sr, ok := r.(singleResolver); ok {
+			single, err := sr.ResolveSong(ctx, song.OriginalURL)
+			if err != nil {
+				return nil, err
+			}
+			return []*Song{single}, nil
+		}
+		return r.Resolve(ctx, song.OriginalURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(songs) == 0 || songs[0].StreamURL == "" {
+		return nil, fmt.Errorf("could not resolve a stream for %s", song.OriginalURL)
+	}
+	return songs[0], nil
+}
+
+// Refresh resolves song's stream URL in place if it is missing or may have expired.
+// mu guards song's fields, which the queue shares with status readers.
+func (reg *ResolverRegistry) Refresh(ctx context.Context, song *Song, mu sync.Locker) error {
+	if song.StreamURL != "" {
+		r, ok := reg.byName[song.Source].(expiringResolver)
+		if !ok || time.Since(song.ResolvedAt) < r.StreamURLMaxAge() {
+			return nil
+		}
+	}
+
+	logFrom(ctx).Debug("Stream URL is missing or stale, resolving", "song_url", song.OriginalURL)
+	return reg.Reresolve(ctx, song, mu)
+}
+
+// Reresolve resolves song's stream URL in place, even if it looks current. The
+// fields are copied under mu; resolving itself runs without it.
+func (reg *ResolverRegistry) Reresolve(ctx context.Context, song *Song, mu sync.Locker) error {
+	fresh, err := reg.Fresh(ctx, song)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	song.StreamURL = fresh.StreamURL
+	song.ResolvedAt = fresh.ResolvedAt
+	song.HTTPHeaders = fresh.HTTPHeaders
+	song.Codec = fresh.Codec
+	song.Bitrate = fresh.Bitrate
+	song.Source = fresh.Source
+
+	// Playlist entries start out with only flat metadata
+	if song.DurationSeconds == 0 {
+		song.DurationSeconds = fresh.DurationSeconds
+		song.Duration = fresh.Duration
+	}
+	if song.Thumbnail == defaultThumbnail {
+		song.Thumbnail = fresh.Thumbnail
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// search.go
+package musicbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// searchResultCount is how many hits the /play query picker offers
+	searchResultCount = 5
+
+	// searchSelectPrefix starts the CustomID of the picker; the requester's user ID follows it
+	searchSelectPrefix = "search_select:"
+)
+
+// errNoResults is a search that found nothing
+var errNoResults = errors.New("no search results")
+
+// SearchResult is a single hit from a yt-dlp search
+type SearchResult struct {
+	Title           string
+	URL             string
+	Channel         string
+	DurationSeconds int
+}
+
+// isURL reports whether input looks like a link rather than search words
+func isURL(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// searchSongs runs a flat yt-dlp search and returns up to limit hits
+func searchSongs(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	logFrom(ctx).Debug("Searching yt-dlp", "query", query)
+
+	out, err := runYTDLPJSON(ctx, fmt.Sprintf("ytsearch%d:%s", limit, query), "--flat-playlist", "-J")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(out.Entries))
+	for _, e := range out.Entries {
+		if e == nil || e.URL == "" {
+			continue
+		}
+		channel := e.Channel
+		if channel == "" {
+			channel = e.Uploader
+		}
+		results = append(results, SearchResult{
+			Title:           e.Title,
+			URL:             e.URL,
+			Channel:         channel,
+			DurationSeconds: int(e.Duration),
+		})
+	}
+	return results, nil
+}
+
+// sendSearchPicker answers a deferred /play with a select menu of the top search hits
+func (gp *GuildPlayer) sendSearchPicker(s *discordgo.Session, i *discordgo.InteractionCreate, query string) error {
+	results, err := searchSongs(withLogger(context.Background(), gp.interactionLog(i)), query, searchResultCount)
+	if err != nil {
+		followupMessage(s, i, fmt.Sprintf("Error searching for song: %v", err))
+		return err
+	}
+	if len(results) == 0 {
+		followupMessage(s, i, fmt.Sprintf("No results found for **%s**.", query))
+		return errNoResults
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(results))
+	for _, r := range results {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncate(r.Title, 100),
+			Description: truncate(fmt.Sprintf("%s · %s", formatDuration(r.DurationSeconds), r.Channel), 100),
+			Value:       r.URL,
+		})
+	}
+
+	_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: fmt.Sprintf("Results for **%s**:", query),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    searchSelectPrefix + i.Member.User.ID,
+						Placeholder: "Pick a song to queue",
+						Options:     options,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callFollowup)
+		gp.interactionLog(i).Warn("Error sending search picker", "err", err)
+		return err
+	}
+	return nil
+}
+
+// handleSearchSelect queues the result picked from a search select menu
+func (gp *GuildPlayer) handleSearchSelect(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	requesterID := strings.TrimPrefix(data.CustomID, searchSelectPrefix)
+	if i.Member == nil || i.Member.User.ID != requesterID {
+		respondEphemeral(s, i, "Only the person who searched can pick a result. Run `/play query:` yourself!")
+		return
+	}
+	if len(data.Values) == 0 {
+		return
+	}
+
+	// Joining and fetching can take longer than the interaction deadline
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error acknowledging search select", "err", err)
+		return
+	}
+
+	content := ""
+	res, err := gp.enqueueURL(s, i, data.Values[0], PlaylistOptions{})
+	queued := err == nil
+	if err != nil {
+		content = err.Error()
+	} else {
+		content = res.Message()
+	}
+
+	// Replace the picker so it can't be used twice
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	if err != nil {
+		gp.interactionLog(i).Warn("Error updating search picker", "err", err)
+	}
+	if queued {
+		gp.startPlayback()
+	}
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
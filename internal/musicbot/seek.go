@@ -0,0 +1,143 @@
+// seek.go
+package musicbot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// minSeekSeconds is the lower bound for the /forward and /rewind options
+var minSeekSeconds = 1.0
+
+// Position returns the absolute playback position of the current song in seconds.
+// TotalPlayTime is the offset ffmpeg was started at, Pos the progress it reported since.
+func (gp *GuildPlayer) Position() float64 {
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+	return gp.PauseState.TotalPlayTime + gp.PauseState.Pos
+}
+
+// Seek restarts the current song's ffmpeg pipeline at pos seconds
+func (gp *GuildPlayer) Seek(pos float64) error {
+	// CurrentSong is guarded by QueueMutex, which comes before PauseState.Mutex
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+
+	song := gp.CurrentSong
+	if song == nil || gp.PauseState.Cmd == nil {
+		return ErrNothingPlaying
+	}
+
+	pos = clampPosition(pos, song.DurationSeconds)
+	gp.log.Info("Seeking", "song_url", song.OriginalURL, "position", pos)
+
+	// playQueue sees SeekReq and replays the current song from TotalPlayTime
+	gp.PauseState.SeekReq = true
+	gp.PauseState.TotalPlayTime = pos
+	gp.PauseState.Pos = 0
+	gp.PauseState.KillReq = true
+	_ = gp.PauseState.Cmd.Process.Kill()
+	return nil
+}
+
+// clampPosition keeps pos within the song, leaving a second so the seek doesn't end it outright
+func clampPosition(pos float64, duration int) float64 {
+	if pos < 0 {
+		return 0
+	}
+	if duration > 0 && pos > float64(duration-1) {
+		return float64(max(duration-1, 0))
+	}
+	return pos
+}
+
+// ParseSeekTarget converts "1:23", "01:02:03", "90", "+30" or "-15" into an absolute position
+func ParseSeekTarget(input string, current float64) (float64, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return 0, fmt.Errorf("empty timestamp")
+	}
+
+	switch input[0] {
+	case '+':
+		offset, err := parseTimestamp(input[1:])
+		if err != nil {
+			return 0, err
+		}
+		return current + offset, nil
+	case '-':
+		offset, err := parseTimestamp(input[1:])
+		if err != nil {
+			return 0, err
+		}
+		return current - offset, nil
+	}
+	return parseTimestamp(input)
+}
+
+// parseTimestamp parses seconds, MM:SS or HH:MM:SS into seconds
+func parseTimestamp(ts string) (float64, error) {
+	parts := strings.Split(ts, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+
+	var total float64
+	for idx, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		// ParseFloat also takes "inf" and "nan", which would reach ffmpeg's -ss
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		// Only the leading field may exceed 59, e.g. "90" or "75:00"
+		if idx > 0 && value >= 60 {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		total = total*60 + value
+	}
+	return total, nil
+}
+
+// seekSlash handles /seek <timestamp>
+func (gp *GuildPlayer) seekSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	input := i.ApplicationCommandData().Options[0].StringValue()
+	target, err := ParseSeekTarget(input, gp.Position())
+	if err != nil {
+		respondMessage(s, i, fmt.Sprintf("Could not parse timestamp: %v. Use `1:23`, `01:02:03`, `+30` or `-15`.", err))
+		return err
+	}
+	return gp.seekAndRespond(s, i, target)
+}
+
+// forwardSlash handles /forward <seconds>
+func (gp *GuildPlayer) forwardSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	seconds := i.ApplicationCommandData().Options[0].IntValue()
+	return gp.seekAndRespond(s, i, gp.Position()+float64(seconds))
+}
+
+// rewindSlash handles /rewind <seconds>
+func (gp *GuildPlayer) rewindSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	seconds := i.ApplicationCommandData().Options[0].IntValue()
+	return gp.seekAndRespond(s, i, gp.Position()-float64(seconds))
+}
+
+func (gp *GuildPlayer) seekAndRespond(s *discordgo.Session, i *discordgo.InteractionCreate, target float64) error {
+	if err := gp.Seek(target); err != nil {
+		respondMessage(s, i, fmt.Sprintf("Cannot seek: %v.", err))
+		return err
+	}
+
+	pos := int(gp.Position())
+	respondMessage(s, i, fmt.Sprintf("Seeked to %s.", formatDuration(pos)))
+
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		gp.updateNowPlayingEmbed(s)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+// settings.go
+package musicbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const settingsFile = "settings.json"
+
+// settingsPermission is required to see and use /settings
+var settingsPermission int64 = discordgo.PermissionManageServer
+
+// minMaxQueueLength is the lower bound of the /settings max_queue_length option; 0 removes the limit
+var minMaxQueueLength = 0.0
+
+// GuildOverrides are the settings a guild changed with /settings; nil fields use the config default
+type GuildOverrides struct {
+	DefaultVolume     *int      `json:"default_volume,omitempty"`
+	MaxQueueLength    *int      `json:"max_queue_length,omitempty"`
+	AnnounceChannelID *string   `json:"announce_channel_id,omitempty"`
+	DJRoleID          *string   `json:"dj_role_id,omitempty"`
+	VoteSkipPercent   *int      `json:"vote_skip_percent,omitempty"`
+	FairQueue         *bool     `json:"fair_queue,omitempty"`
+	MaxUserSongs      *int      `json:"max_user_songs,omitempty"`
+	MaxUserDuration   *Duration `json:"max_user_duration,omitempty"`
+	AlwaysOn          *bool     `json:"always_on,omitempty"`
+	Volume            *int      `json:"volume,omitempty"` // Last level set with /volume, kept across restarts
+}
+
+// apply layers the overrides on top of defaults
+func (o GuildOverrides) apply(defaults GuildConfig) GuildConfig {
+	if o.DefaultVolume != nil {
+		defaults.DefaultVolume = *o.DefaultVolume
+	}
+	if o.MaxQueueLength != nil {
+		defaults.MaxQueueLength = *o.MaxQueueLength
+	}
+	if o.AnnounceChannelID != nil {
+		defaults.AnnounceChannelID = *o.AnnounceChannelID
+	}
+	if o.DJRoleID != nil {
+		defaults.DJRoleID = *o.DJRoleID
+	}
+	if o.VoteSkipPercent != nil {
+		defaults.VoteSkipPercent = *o.VoteSkipPercent
+	}
+	if o.FairQueue != nil {
+		defaults.FairQueue = *o.FairQueue
+	}
+	if o.MaxUserSongs != nil {
+		defaults.MaxUserSongs = *o.MaxUserSongs
+	}
+	if o.MaxUserDuration != nil {
+		defaults.MaxUserDuration = *o.MaxUserDuration
+	}
+	if o.AlwaysOn != nil {
+		defaults.AlwaysOn = *o.AlwaysOn
+	}
+	return defaults
+}
+
+// SettingsStore keeps every guild's overrides in one JSON file in the data directory
+type SettingsStore struct {
+	path   string
+	mu     sync.Mutex
+	guilds map[string]GuildOverrides
+}
+
+// NewSettingsStore loads the overrides saved in dataDir
+func NewSettingsStore(dataDir string) (*SettingsStore, error) {
+	store := &SettingsStore{
+		path:   filepath.Join(dataDir, settingsFile),
+		guilds: make(map[string]GuildOverrides),
+	}
+
+	data, err := os.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.guilds); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", store.path, err)
+	}
+	return store, nil
+}
+
+// Get returns the overrides for guildID
+func (store *SettingsStore) Get(guildID string) GuildOverrides {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.guilds[guildID]
+}
+
+// Update changes guildID's overrides and saves the file
+func (store *SettingsStore) Update(guildID string, change func(*GuildOverrides)) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	o := store.guilds[guildID]
+	change(&o)
+	if o == (GuildOverrides{}) {
+		delete(store.guilds, guildID)
+	} else {
+		store.guilds[guildID] = o
+	}
+	return store.save()
+}
+
+// save writes the file atomically; the caller must hold mu
+func (store *SettingsStore) save() error {
+	data, err := json.MarshalIndent(store.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store.path), 0o755); err != nil {
+		return err
+	}
+	tmp := store.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, store.path)
+}
+
+// guildConfig returns the effective settings for guildID
+func (bot *MusicBot) guildConfig(guildID string) GuildConfig {
+	if bot.Settings == nil {
+		return bot.Config.GuildDefaults
+	}
+	return bot.Settings.Get(guildID).apply(bot.Config.GuildDefaults)
+}
+
+// guildVolume returns the level guildID last set with /volume, or its default volume
+func (bot *MusicBot) guildVolume(guildID string) int {
+	if bot.Settings != nil {
+		if v := bot.Settings.Get(guildID).Volume; v != nil {
+			return *v
+		}
+	}
+	return bot.guildConfig(guildID).DefaultVolume
+}
+
+// settingsSlash routes the /settings subcommands
+func (gp *GuildPlayer) settingsSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	sub := i.ApplicationCommandData().Options[0]
+
+	// Discord hides the command from other members, but permissions can be changed per server
+	if i.Member == nil || i.Member.Permissions&settingsPermission == 0 {
+		respondEphemeral(s, i, "You need the Manage Server permission to change settings.")
+		return errors.New("missing the Manage Server permission")
+	}
+
+	var err error
+	var volume *int // Applied to the running player once the change is saved
+	switch sub.Name {
+	case "show":
+	case "set":
+		err = gp.bot.Settings.Update(gp.GuildID, func(o *GuildOverrides) {
+			for _, opt := range sub.Options {
+				switch opt.Name {
+				case "default_volume":
+					v := int(opt.IntValue())
+					o.DefaultVolume = &v
+					volume = &v
+				case "max_queue_length":
+					v := int(opt.IntValue())
+					o.MaxQueueLength = &v
+				case "announce_channel":
+					v := opt.ChannelValue(nil).ID
+					o.AnnounceChannelID = &v
+				case "dj_role":
+					v := opt.RoleValue(nil, "").ID
+					o.DJRoleID = &v
+				case "open_controls":
+					// Lets anyone control playback again, overriding a default DJ role
+					if opt.BoolValue() {
+						v := ""
+						o.DJRoleID = &v
+					}
+				case "vote_skip_percent":
+					v := int(opt.IntValue())
+					o.VoteSkipPercent = &v
+				case "fair_queue":
+					v := opt.BoolValue()
+					o.FairQueue = &v
+				case "max_user_songs":
+					v := int(opt.IntValue())
+					o.MaxUserSongs = &v
+				case "max_user_minutes":
+					v := Duration{time.Duration(opt.IntValue()) * time.Minute}
+					o.MaxUserDuration = &v
+				case "always_on":
+					v := opt.BoolValue()
+					o.AlwaysOn = &v
+				}
+			}
+		})
+	case "reset":
+		err = gp.bot.Settings.Update(gp.GuildID, func(o *GuildOverrides) {
+			// The /volume level isn't a setting, so it survives a reset
+			*o = GuildOverrides{Volume: o.Volume}
+		})
+	default:
+		gp.interactionLog(i).Warn("Unknown settings subcommand", "subcommand", sub.Name)
+		return fmt.Errorf("unknown subcommand %q", sub.Name)
+	}
+
+	if err != nil {
+		gp.interactionLog(i).Error("Error saving settings", "err", err)
+		respondEphemeral(s, i, fmt.Sprintf("Error saving settings: %v", err))
+		return err
+	}
+	if volume != nil {
+		// Apply it now too, rather than only to the next player
+		_ = gp.SetVolume(*volume)
+	}
+	// 24/7 mode may have changed what counts as idle
+	gp.checkListeners()
+	gp.checkIdle()
+	gp.respondSettings(s, i)
+	return nil
+}
+
+// respondSettings shows the effective settings, marking the ones this guild overrides
+func (gp *GuildPlayer) respondSettings(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	cfg := gp.bot.guildConfig(gp.GuildID)
+	o := gp.bot.Settings.Get(gp.GuildID)
+
+	source := func(overridden bool) string {
+		if overridden {
+			return ""
+		}
+		return " *(default)*"
+	}
+	maxQueue := "no limit"
+	if cfg.MaxQueueLength > 0 {
+		maxQueue = fmt.Sprintf("%d songs", cfg.MaxQueueLength)
+	}
+	announce := "off"
+	if cfg.AnnounceChannelID != "" {
+		announce = "<#" + cfg.AnnounceChannelID + ">"
+	}
+	queueOrder := "first come, first served"
+	if cfg.FairQueue {
+		queueOrder = "fair, requesters take turns"
+	}
+	userSongs := "no limit"
+	if cfg.MaxUserSongs > 0 {
+		userSongs = fmt.Sprintf("%d songs", cfg.MaxUserSongs)
+	}
+	userDuration := "no limit"
+	if cfg.MaxUserDuration.Duration > 0 {
+		userDuration = formatDuration(int(cfg.MaxUserDuration.Duration / time.Second))
+	}
+	alwaysOn := fmt.Sprintf("off, leaves after %s idle", gp.bot.Config.IdleTimeout)
+	if cfg.AlwaysOn {
+		alwaysOn = "on, stays in voice"
+	}
+	djRole := "none, anyone can control playback"
+	if cfg.DJRoleID != "" {
+		djRole = "<@&" + cfg.DJRoleID + ">"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Server Settings",
+		Color: gp.bot.Config.Colors.Playing,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Default volume", Value: fmt.Sprintf("%d%%", cfg.DefaultVolume) + source(o.DefaultVolume != nil), Inline: true},
+			{Name: "Max queue length", Value: maxQueue + source(o.MaxQueueLength != nil), Inline: true},
+			{Name: "Announce channel", Value: announce + source(o.AnnounceChannelID != nil), Inline: true},
+			{Name: "DJ role", Value: djRole + source(o.DJRoleID != nil), Inline: true},
+			{Name: "Vote skip", Value: fmt.Sprintf("%d%% of listeners", cfg.VoteSkipPercent) + source(o.VoteSkipPercent != nil), Inline: true},
+			{Name: "Queue order", Value: queueOrder + source(o.FairQueue != nil), Inline: true},
+			{Name: "Songs per user", Value: userSongs + source(o.MaxUserSongs != nil), Inline: true},
+			{Name: "Music per user", Value: userDuration + source(o.MaxUserDuration != nil), Inline: true},
+			{Name: "24/7 mode", Value: alwaysOn + source(o.AlwaysOn != nil), Inline: true},
+		},
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Failed to respond to settings command", "err", err)
+	}
+}
+
+// announceSong posts the song that just started to the guild's announce channel, if one is set
+func (gp *GuildPlayer) announceSong(song *Song) {
+	channelID := gp.bot.guildConfig(gp.GuildID).AnnounceChannelID
+	if channelID == "" {
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Now Playing:",
+		Description: fmt.Sprintf("🎵 **[%s](%s)**", song.Name, song.OriginalURL),
+		Color:       gp.bot.Config.Colors.Playing,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Duration", Value: song.Duration, Inline: true},
+		},
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: gp.bot.Config.thumbnail(song.Thumbnail),
+		},
+	}
+	if _, err := gp.Session.ChannelMessageSendEmbed(channelID, embed); err != nil {
+		gp.log.Warn("Failed to announce song", "channel_id", channelID, "err", err)
+	}
+}
@@ -0,0 +1,142 @@
+// shutdown.go
+package musicbot
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// silenceFrame is an Opus frame of silence; Discord asks for a few after the
+// last audio frame so clients don't interpolate the cut-off audio
+var silenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+const silenceFrames = 5
+
+// shuttingDown reports whether Shutdown has been called
+func (bot *MusicBot) shuttingDown() bool {
+	return bot.ctx.Err() != nil
+}
+
+// Shutdown stops playback in every guild, saves state for the next start, marks
+// the Now Playing embeds as offline and disconnects from voice. It returns
+// ctx's error if the players did not finish cleaning up in time.
+func (bot *MusicBot) Shutdown(ctx context.Context) error {
+	slog.Info("Shutting down Music Bot")
+	bot.cancel()
+
+	players := bot.allPlayers()
+
+	var wg sync.WaitGroup
+	for _, gp := range players {
+		wg.Add(1)
+		go func(gp *GuildPlayer) {
+			defer wg.Done()
+			gp.shutdown(ctx)
+		}(gp)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Shutdown deadline reached before all players stopped", "err", ctx.Err())
+		return ctx.Err()
+	}
+	slog.Info("Music Bot shut down cleanly")
+	return nil
+}
+
+// shutdown stops this guild's playback and leaves its voice channel
+func (gp *GuildPlayer) shutdown(ctx context.Context) {
+	gp.PauseState.Mutex.Lock()
+	if gp.PauseState.Cmd != nil {
+		gp.log.Debug("Stopping ffmpeg", "ffmpeg_pid", gp.PauseState.Cmd.Process.Pid)
+		gp.PauseState.KillReq = true
+		_ = gp.PauseState.Cmd.Process.Kill()
+	}
+	gp.PauseState.Mutex.Unlock()
+
+	// playSong holds PlaybackMutex until ffmpeg has exited and its pipes are drained
+	stopped := make(chan struct{})
+	go func() {
+		gp.PlaybackMutex.Lock()
+		gp.PlaybackMutex.Unlock()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		gp.log.Warn("Playback did not stop before the deadline")
+	}
+
+	// playQueue leaves the queue untouched once shutdown starts, so this is what
+	// was playing. Save before disconnecting so the voice channel is recorded.
+	gp.saveState()
+
+	if vc := gp.voiceConn(); vc != nil {
+		gp.sendSilence(ctx, vc)
+	}
+	gp.retireEmbed("Bot offline", "The bot is restarting or offline.")
+
+	if vc := gp.voice.Swap(nil); vc != nil {
+		gp.log.Info("Disconnecting from voice", "channel_id", gp.voiceChannelIDOf(vc))
+		if err := vc.Disconnect(); err != nil {
+			gp.log.Warn("Error disconnecting from voice", "err", err)
+		}
+	}
+}
+
+// sendSilence sends trailing silence frames so the last audio frame isn't smeared
+func (gp *GuildPlayer) sendSilence(ctx context.Context, vc *discordgo.VoiceConnection) {
+	for n := 0; n < silenceFrames; n++ {
+		select {
+		case vc.OpusSend <- silenceFrame:
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			// Voice connection isn't draining frames, nothing more to do
+			return
+		}
+	}
+	vc.Speaking(false)
+}
+
+// retireEmbed replaces the Now Playing embed with a notice and drops its
+// buttons, which would otherwise fail once the bot is gone
+func (gp *GuildPlayer) retireEmbed(title, description string) {
+	if gp.CurrentSongMessageID == "" || gp.CurrentSongChannelID == "" {
+		return
+	}
+
+	gp.QueueMutex.Lock()
+	if gp.CurrentSong != nil {
+		description += "\nLast playing: **" + gp.CurrentSong.Name + "**"
+	}
+	gp.QueueMutex.Unlock()
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       gp.bot.Config.Colors.Offline,
+	}
+	components := []discordgo.MessageComponent{}
+	_, err := gp.Session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    gp.CurrentSongChannelID,
+		ID:         gp.CurrentSongMessageID,
+		Embed:      embed,
+		Components: &components,
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callMessageEdit)
+		gp.log.Warn("Failed to retire Now Playing embed", "err", err)
+	}
+}
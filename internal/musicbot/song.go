@@ -3,12 +3,8 @@
 package musicbot
 
 import (
-	"bytes"
 	"fmt"
-	"log"
-	"os/exec"
-	"strconv"
-	"strings"
+	"time"
 )
 
 // Song holds the metadata for a track
@@ -19,77 +15,31 @@ type Song struct {
 	DurationSeconds int
 	Thumbnail       string
 	OriginalURL     string
+	ResolvedAt      time.Time // When StreamURL was fetched; signed stream URLs expire
+	Source          string    // Name of the Resolver that produced the song
+	RequesterID     string    // Discord user who queued the song, empty when queued through the API
+
+	WebpageURL  string
+	Uploader    string
+	UploadDate  string // YYYYMMDD as reported by yt-dlp
+	IsLive      bool
+	Chapters    []Chapter
+	Extractor   string
+	Codec       string
+	Bitrate     float64           // Audio bitrate in kbit/s
+	HTTPHeaders map[string]string // Headers ffmpeg must send to fetch StreamURL
 }
 
-func fetchSongInfo(url string) (*Song, error) {
-	log.Printf("Starting fetchSongInfo for URL: %s", url)
-
-	cmd := exec.Command("yt-dlp", "-f", "bestaudio", "--get-title", "--get-url", "--get-duration", "--get-thumbnail", url)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Log before executing the command
-	log.Println("Running yt-dlp command...")
-	err := cmd.Run()
-	if err != nil {
-		log.Printf("yt-dlp command failed: %v\nstderr: %s", err, stderr.String())
-		return nil, fmt.Errorf("yt-dlp error: %v\n%s", err, stderr.String())
-	}
-
-	// Log raw stdout output
-	log.Printf("yt-dlp stdout: %s", stdout.String())
-
-	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-	if len(lines) < 4 {
-		log.Printf("yt-dlp returned insufficient information. Output: %s", stdout.String())
-		return nil, fmt.Errorf("could not fetch all song information")
-	}
-
-	// Assign fields correctly
-	title := lines[0]
-	streamURL := lines[1]
-	durationStr := lines[3]
-	thumbnail := lines[2]
-
-	log.Printf("Extracted Data:\n - Title: %s\n - Stream URL: %s\n - Duration: %s\n - Thumbnail: %s",
-		title, streamURL, durationStr, thumbnail)
-
-	// Validate and sanitize the thumbnail URL
-	if !strings.HasPrefix(thumbnail, "http") {
-		log.Printf("Invalid thumbnail URL from yt-dlp: %s", thumbnail)
-		thumbnail = "https://example.com/default-thumbnail.png" // Fallback URL
-	}
-
-	// Parse duration into seconds
-	durationParts := strings.Split(durationStr, ":")
-	var durationSeconds int
-	if len(durationParts) == 2 {
-		minutes, _ := strconv.Atoi(durationParts[0])
-		seconds, _ := strconv.Atoi(durationParts[1])
-		durationSeconds = minutes*60 + seconds
-	} else if len(durationParts) == 3 {
-		hours, _ := strconv.Atoi(durationParts[0])
-		minutes, _ := strconv.Atoi(durationParts[1])
-		seconds, _ := strconv.Atoi(durationParts[2])
-		durationSeconds = hours*3600 + minutes*60 + seconds
-	} else {
-		log.Printf("Unexpected duration format: %s", durationStr)
-		durationSeconds = 0 // Default to 0 if parsing fails
-	}
-
-	log.Printf("Parsed Duration: %d seconds (%s)", durationSeconds, formatDuration(durationSeconds))
-
-	return &Song{
-		Name:            title,
-		StreamURL:       streamURL,
-		Duration:        formatDuration(durationSeconds), // Format as HH:MM:SS or MM:SS
-		DurationSeconds: durationSeconds,
-		Thumbnail:       thumbnail,
-		OriginalURL:     url,
-	}, nil
+// Chapter is a named section of a song, in seconds from its start
+type Chapter struct {
+	Title string
+	Start float64
+	End   float64
 }
 
+// defaultThumbnail is shown when a source has no usable thumbnail
+const defaultThumbnail = "https://example.com/default-thumbnail.png"
+
 // Helper function to format duration in seconds into HH:MM:SS or MM:SS
 func formatDuration(seconds int) string {
 	hours := seconds / 3600
@@ -101,12 +51,3 @@ func formatDuration(seconds int) string {
 	}
 	return fmt.Sprintf("%02d:%02d", minutes, secs)
 }
-
-func safeFetchSongInfo(url string) (*Song, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Panic in fetchSongInfo: %v", r)
-		}
-	}()
-	return fetchSongInfo(url)
-}
@@ -0,0 +1,271 @@
+// state.go
+package musicbot
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	stateDirName = "state"
+	// stateSaveInterval is how often changed or playing guilds are written to disk
+	stateSaveInterval = 5 * time.Second
+)
+
+// guildState is the on-disk snapshot of one guild's player
+type guildState struct {
+	GuildID        string
+	VoiceChannelID string
+	Queue          []*Song
+	CurrentSong    *Song
+	Position       float64 // Seconds into CurrentSong
+	Paused         bool
+	LoopMode       LoopMode
+	Volume         int
+	MessageID      string // Now Playing embed, so the restored player keeps updating it
+	ChannelID      string
+	SavedAt        time.Time
+}
+
+// empty reports whether there is nothing worth restoring
+func (st *guildState) empty() bool {
+	return st.CurrentSong == nil && len(st.Queue) == 0
+}
+
+// StateStore persists each guild's queue and playback position under DATA_DIR/state
+// so a restart picks up where the previous process left off
+type StateStore struct {
+	dir   string
+	mu    sync.Mutex
+	dirty map[string]*GuildPlayer
+}
+
+// NewStateStore keeps snapshots in dataDir/state
+func NewStateStore(dataDir string) *StateStore {
+	return &StateStore{
+		dir:   filepath.Join(dataDir, stateDirName),
+		dirty: make(map[string]*GuildPlayer),
+	}
+}
+
+func (store *StateStore) path(guildID string) string {
+	return filepath.Join(store.dir, guildID+".json")
+}
+
+// markDirty schedules gp to be saved on the next tick
+func (store *StateStore) markDirty(gp *GuildPlayer) {
+	store.mu.Lock()
+	store.dirty[gp.GuildID] = gp
+	store.mu.Unlock()
+}
+
+// save writes one snapshot, removing the file once there is nothing left to resume
+func (store *StateStore) save(st *guildState) error {
+	if st.empty() {
+		err := os.Remove(store.path(st.GuildID))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(store.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Write then rename so a crash mid-save never leaves a truncated snapshot
+	tmp := store.path(st.GuildID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, store.path(st.GuildID))
+}
+
+// load reads every saved snapshot
+func (store *StateStore) load() ([]*guildState, error) {
+	entries, err := os.ReadDir(store.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*guildState
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(store.dir, entry.Name()))
+		if err != nil {
+			slog.Warn("Could not read saved state", "file", entry.Name(), "err", err)
+			continue
+		}
+		var st guildState
+		if err := json.Unmarshal(data, &st); err != nil {
+			slog.Warn("Could not parse saved state", "file", entry.Name(), "err", err)
+			continue
+		}
+		states = append(states, &st)
+	}
+	return states, nil
+}
+
+// snapshot captures the player's state for saving
+func (gp *GuildPlayer) snapshot() *guildState {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+
+	queue := make([]*Song, 0, len(gp.Queue))
+	for _, song := range gp.Queue {
+		queue = append(queue, song.persisted())
+	}
+	st := &guildState{
+		GuildID:     gp.GuildID,
+		Queue:       queue,
+		CurrentSong: gp.CurrentSong.persisted(),
+		Position:    gp.PauseState.TotalPlayTime + gp.PauseState.Pos,
+		Paused:      gp.PauseState.Paused,
+		LoopMode:    gp.LoopMode,
+		Volume:      gp.Volume,
+		MessageID:   gp.CurrentSongMessageID,
+		ChannelID:   gp.CurrentSongChannelID,
+		SavedAt:     time.Now(),
+	}
+	st.VoiceChannelID = gp.voiceChannelID()
+	return st
+}
+
+// persisted copies song without its signed stream URL and headers, which will have
+// expired by the time a snapshot is restored; playQueue resolves OriginalURL again
+func (song *Song) persisted() *Song {
+	if song == nil {
+		return nil
+	}
+	saved := *song
+	saved.StreamURL = ""
+	saved.HTTPHeaders = nil
+	saved.ResolvedAt = time.Time{}
+	return &saved
+}
+
+// stateChanged asks the state store, if any, to save this guild soon and
+// tells watchers such as the dashboard to redraw
+func (gp *GuildPlayer) stateChanged() {
+	if gp.bot.State != nil {
+		gp.bot.State.markDirty(gp)
+	}
+	gp.notifyWatchers()
+}
+
+// saveStateLoop periodically writes guilds that changed or are mid-song
+func (bot *MusicBot) saveStateLoop() {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-bot.ctx.Done():
+			// Shutdown saves every guild itself once playback has stopped
+			return
+		}
+
+		// Playing guilds are saved every tick so the resume offset stays current
+		for _, gp := range bot.allPlayers() {
+			if gp.loopRunning.Load() {
+				bot.State.markDirty(gp)
+			}
+		}
+
+		bot.State.mu.Lock()
+		dirty := bot.State.dirty
+		bot.State.dirty = make(map[string]*GuildPlayer)
+		bot.State.mu.Unlock()
+
+		for _, gp := range dirty {
+			gp.saveState()
+		}
+	}
+}
+
+// saveState writes this guild's state immediately, e.g. before the process exits
+func (gp *GuildPlayer) saveState() {
+	if gp.bot.State == nil {
+		return
+	}
+	if err := gp.bot.State.save(gp.snapshot()); err != nil {
+		gp.log.Error("Error saving state", "err", err)
+	}
+}
+
+// restoreState loads the queues saved by the previous run, then rejoins their voice
+// channels and resumes playback in the background so Start isn't held up
+func (bot *MusicBot) restoreState() {
+	states, err := bot.State.load()
+	if err != nil {
+		slog.Error("Could not load saved state", "err", err)
+		return
+	}
+
+	for _, st := range states {
+		if st.empty() {
+			continue
+		}
+		gp := bot.player(st.GuildID)
+		gp.restore(st)
+		go func() {
+			if err := gp.resume(st.VoiceChannelID); err != nil {
+				gp.log.Error("Could not restore playback", "err", err)
+			}
+		}()
+	}
+}
+
+// restore loads a snapshot into an idle player, ready to resume from the saved offset
+func (gp *GuildPlayer) restore(st *guildState) {
+	gp.log.Info("Restoring playback", "queued", len(st.Queue))
+
+	gp.QueueMutex.Lock()
+	gp.Queue = st.Queue
+	gp.CurrentSong = st.CurrentSong
+	gp.LoopMode = st.LoopMode
+	gp.PauseState.Mutex.Lock()
+	gp.PauseState.TotalPlayTime = st.Position
+	gp.PauseState.Pos = 0
+	gp.PauseState.Paused = st.Paused
+	gp.Volume = st.Volume
+	gp.PauseState.Mutex.Unlock()
+	gp.QueueMutex.Unlock()
+
+	gp.CurrentSongMessageID = st.MessageID
+	gp.CurrentSongChannelID = st.ChannelID
+	gp.EmbedInitialized = st.MessageID != ""
+}
+
+// resume rejoins the voice channel a restored player was saved in and starts playback
+func (gp *GuildPlayer) resume(voiceChannelID string) error {
+	if voiceChannelID == "" {
+		// Keep the queue; the next /play joins a channel and starts it
+		return nil
+	}
+	vc, err := gp.Session.ChannelVoiceJoin(gp.GuildID, voiceChannelID, false, true)
+	if err != nil {
+		return err
+	}
+	gp.setVoiceConn(vc)
+	gp.startPlayback()
+	return nil
+}
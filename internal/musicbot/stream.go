@@ -0,0 +1,70 @@
+// stream.go
+package musicbot
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// earlyEndSlack is how far short of its duration a stream may end and still count
+// as finished, since durations reported by sources are rounded and a little off
+const earlyEndSlack = 5.0 // seconds
+
+// httpReconnectArgs let ffmpeg reopen a dropped HTTP stream itself before
+// playQueue has to resume the song
+var httpReconnectArgs = []string{
+	"-reconnect", "1",
+	"-reconnect_streamed", "1",
+	"-reconnect_delay_max", "5",
+}
+
+// isHTTPStream reports whether ffmpeg reads streamURL over HTTP
+func isHTTPStream(streamURL string) bool {
+	return strings.HasPrefix(streamURL, "http://") || strings.HasPrefix(streamURL, "https://")
+}
+
+// endedEarly reports whether song stopped well before its end while still the
+// current song, which means its stream dropped rather than finished
+func (gp *GuildPlayer) endedEarly(song *Song) bool {
+	// Live streams have no end to compare against
+	if song.IsLive || song.DurationSeconds == 0 {
+		return false
+	}
+
+	gp.QueueMutex.Lock()
+	current := gp.CurrentSong == song
+	gp.QueueMutex.Unlock()
+	if !current {
+		return false // Stopped or cleared
+	}
+
+	gp.PauseState.Mutex.Lock()
+	pos := gp.PauseState.TotalPlayTime + gp.PauseState.Pos
+	gp.PauseState.Mutex.Unlock()
+	return pos < float64(song.DurationSeconds)-earlyEndSlack
+}
+
+// resumeStream fetches a fresh stream URL for song so the next run of playSong
+// picks up at the position it reached. An old URL is kept when resolving fails,
+// since the drop may have been a network blip.
+func (gp *GuildPlayer) resumeStream(lg *slog.Logger, song *Song, attempt int) {
+	gp.PauseState.Mutex.Lock()
+	pos := gp.PauseState.TotalPlayTime + gp.PauseState.Pos
+	gp.PauseState.Mutex.Unlock()
+	lg.Warn("Stream ended early, resuming", "song", song.Name, "position", formatDuration(int(pos)),
+		"duration", song.Duration, "attempt", attempt, "max_attempts", gp.bot.Config.StreamRetries)
+	metrics.streamResumes.inc("resumed")
+
+	// Back off a little more each time so a source that is down isn't hammered
+	select {
+	case <-time.After(time.Duration(attempt) * time.Second):
+	case <-gp.bot.ctx.Done():
+		return
+	}
+
+	if err := gp.bot.Resolvers.Reresolve(withLogger(context.Background(), lg), song, &gp.QueueMutex); err != nil {
+		lg.Warn("Error resolving a fresh stream URL, retrying the old one", "err", err)
+	}
+}
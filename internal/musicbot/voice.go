@@ -0,0 +1,220 @@
+// voice.go
+package musicbot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// errVoiceLost is returned by playSong when the voice connection stops taking audio
+var errVoiceLost = errors.New("voice connection lost")
+
+// voiceChannelTypes are the channels /join and /move accept
+var voiceChannelTypes = []discordgo.ChannelType{discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildStageVoice}
+
+// firstReconnectDelay is the wait before the second attempt to rejoin; it doubles after each failure
+const firstReconnectDelay = 2 * time.Second
+
+// reconnectVoice rejoins the channel the bot is in, or was moved to, after the
+// voice connection stopped taking audio. It gives up after the configured
+// number of attempts.
+func (gp *GuildPlayer) reconnectVoice() error {
+	vc := gp.voiceConn()
+	if vc == nil {
+		return ErrNotInVoice
+	}
+	// discordgo follows moves, so this is where a moderator last put the bot
+	channelID := gp.voiceChannelID()
+
+	attempts := gp.bot.Config.VoiceRetries
+	delay := firstReconnectDelay
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		gp.log.Warn("Voice connection lost, rejoining", "channel_id", channelID, "attempt", attempt, "max_attempts", attempts)
+
+		// A clean disconnect first, since rejoining a channel Discord thinks
+		// we're still in doesn't start a new voice session
+		_ = vc.Disconnect()
+		vc, err = gp.Session.ChannelVoiceJoin(gp.GuildID, channelID, false, true)
+		if err == nil {
+			gp.setVoiceConn(vc)
+			gp.log.Info("Rejoined voice channel", "channel_id", channelID, "attempt", attempt)
+			return nil
+		}
+		gp.log.Warn("Failed to rejoin voice channel", "channel_id", channelID, "attempt", attempt, "err", err)
+		if vc == nil {
+			vc = gp.voiceConn()
+		}
+
+		if attempt < attempts {
+			select {
+			case <-time.After(delay):
+			case <-gp.bot.ctx.Done():
+				return gp.bot.ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+	return fmt.Errorf("could not rejoin after %d attempts: %v", attempts, err)
+}
+
+// giveUpVoice leaves voice after reconnecting failed, keeping the queue and the
+// position in the current song so /join picks up where playback stopped
+func (gp *GuildPlayer) giveUpVoice(err error) {
+	gp.log.Error("Giving up on the voice connection", "err", err)
+
+	if vc := gp.voice.Swap(nil); vc != nil {
+		_ = vc.Disconnect()
+	}
+	gp.retireEmbed("Disconnected", "Lost the voice connection.")
+	gp.CurrentSongMessageID = ""
+	gp.CurrentSongChannelID = ""
+
+	notice := gp.noticeChannelID.Load()
+	if notice == nil || *notice == "" {
+		return
+	}
+	channelID := *notice
+	msg := "⚠️ Lost the voice connection and couldn't rejoin. The queue is saved, use `/join` to pick up where it stopped."
+	if _, err := gp.Session.ChannelMessageSend(channelID, msg); err != nil {
+		gp.log.Warn("Failed to send voice notice", "channel_id", channelID, "err", err)
+	}
+}
+
+// voiceConn returns the current voice connection, or nil when not in a channel.
+// Callers that use it more than once should keep the result, since a reconnect,
+// /move or /leave can replace it at any time.
+func (gp *GuildPlayer) voiceConn() *discordgo.VoiceConnection {
+	return gp.voice.Load()
+}
+
+// setVoiceConn replaces the voice connection; nil means not in a channel
+func (gp *GuildPlayer) setVoiceConn(vc *discordgo.VoiceConnection) {
+	gp.voice.Store(vc)
+}
+
+// voiceChannelID returns the channel the bot is connected to, or "" when it isn't
+func (gp *GuildPlayer) voiceChannelID() string {
+	return gp.voiceChannelIDOf(gp.voiceConn())
+}
+
+// voiceChannelIDOf returns vc's channel, or "" for a nil connection
+func (gp *GuildPlayer) voiceChannelIDOf(vc *discordgo.VoiceConnection) string {
+	if vc == nil {
+		return ""
+	}
+	vc.RLock()
+	defer vc.RUnlock()
+	return vc.ChannelID
+}
+
+// joinFor joins channelID for someone asking the bot over, unless that would
+// pull it away from people listening in another channel
+func (gp *GuildPlayer) joinFor(channelID string) error {
+	if current := gp.voiceChannelID(); current != "" && current != channelID && len(gp.listeners()) > 0 {
+		return fmt.Errorf("%w (<#%s>), ask a DJ to `/move` it", ErrOtherChannel, current)
+	}
+	return gp.joinChannel(channelID)
+}
+
+// joinSlash joins the given voice channel, or the caller's, and picks up a queue
+// left behind when the bot lost its connection
+func (gp *GuildPlayer) joinSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	channelID := ""
+	if opts := i.ApplicationCommandData().Options; len(opts) > 0 {
+		channelID = opts[0].ChannelValue(nil).ID
+	} else if vs, err := s.State.VoiceState(i.GuildID, interactionUserID(i)); err == nil {
+		channelID = vs.ChannelID
+	}
+	if channelID == "" {
+		respondEphemeral(s, i, "Join a voice channel first, or pick one to join.")
+		return errors.New("no voice channel to join")
+	}
+	if channelID == gp.voiceChannelID() {
+		respondEphemeral(s, i, fmt.Sprintf("Already in <#%s>.", channelID))
+		return nil
+	}
+
+	// Joining can take a few seconds, longer than Discord waits for a response
+	if err := gp.deferResponse(s, i); err != nil {
+		return err
+	}
+	connected := gp.voiceChannelID() != ""
+	if err := gp.joinFor(channelID); err != nil {
+		gp.interactionLog(i).Warn("Error joining voice channel", "channel_id", channelID, "err", err)
+		followupMessage(s, i, fmt.Sprintf("Error joining voice channel: %v", err))
+		return err
+	}
+	gp.interactionLog(i).Info("Joined voice channel", "channel_id", channelID)
+
+	gp.QueueMutex.Lock()
+	queued := gp.CurrentSong != nil || len(gp.Queue) > 0
+	gp.QueueMutex.Unlock()
+	if !connected && queued {
+		gp.startPlayback()
+		followupMessage(s, i, fmt.Sprintf("Joined <#%s>, picking up the queue.", channelID))
+		return nil
+	}
+	gp.checkIdle()
+	followupMessage(s, i, fmt.Sprintf("Joined <#%s>.", channelID))
+	return nil
+}
+
+// moveSlash moves the bot to another voice channel, keeping the current song's position
+func (gp *GuildPlayer) moveSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	channelID := i.ApplicationCommandData().Options[0].ChannelValue(nil).ID
+	current := gp.voiceChannelID()
+	if current == "" {
+		respondEphemeral(s, i, "I'm not in a voice channel, use `/join` first.")
+		return ErrNotInVoice
+	}
+	if channelID == current {
+		respondEphemeral(s, i, fmt.Sprintf("Already in <#%s>.", channelID))
+		return nil
+	}
+
+	if err := gp.deferResponse(s, i); err != nil {
+		return err
+	}
+	if err := gp.joinChannel(channelID); err != nil {
+		gp.interactionLog(i).Warn("Error moving voice channel", "from", current, "channel_id", channelID, "err", err)
+		followupMessage(s, i, fmt.Sprintf("Error moving to <#%s>: %v", channelID, err))
+		return err
+	}
+	gp.interactionLog(i).Info("Moved voice channel", "from", current, "channel_id", channelID)
+	followupMessage(s, i, fmt.Sprintf("Moved to <#%s>.", channelID))
+	return nil
+}
+
+// leaveSlash leaves the voice channel, keeping the queue like an idle leave or a
+// lost connection does, so /join picks up where it stopped. leaveVoice resets the
+// pause state and waits for playSong, so the old playQueue exits instead of
+// waiting on a pause nobody can lift.
+func (gp *GuildPlayer) leaveSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if gp.voiceChannelID() == "" {
+		respondEphemeral(s, i, "I'm not in a voice channel.")
+		return ErrNotInVoice
+	}
+
+	gp.retireEmbed("Disconnected", "Left the voice channel.")
+	gp.CurrentSongMessageID = ""
+	gp.CurrentSongChannelID = ""
+	gp.leaveVoice()
+	respondMessage(s, i, "Left the voice channel. The queue is kept, use `/join` to pick it up or `/stop` to clear it.")
+	return nil
+}
+
+// deferResponse acknowledges i so a slow handler can answer with followupMessage
+func (gp *GuildPlayer) deferResponse(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		metrics.discordAPIErrors.inc(callInteractionRespond)
+		gp.interactionLog(i).Warn("Error acknowledging interaction", "err", err)
+	}
+	return err
+}
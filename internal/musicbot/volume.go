@@ -0,0 +1,107 @@
+// volume.go
+package musicbot
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultVolume = 100
+	maxVolume     = 200
+
+	// softClipKnee is where soft clipping starts bending samples, as a fraction of full scale
+	softClipKnee = 0.75
+)
+
+// minVolumeOption and maxVolumeOption bound the /volume level option
+var (
+	minVolumeOption = 0.0
+	maxVolumeOption = float64(maxVolume)
+)
+
+func (gp *GuildPlayer) volume() int {
+	gp.PauseState.Mutex.Lock()
+	defer gp.PauseState.Mutex.Unlock()
+	return gp.Volume
+}
+
+// SetVolume changes the level and saves it for the guild; playSong picks it up
+// on the next frame
+func (gp *GuildPlayer) SetVolume(level int) error {
+	if level < 0 || level > maxVolume {
+		return fmt.Errorf("volume must be between 0 and %d", maxVolume)
+	}
+
+	gp.PauseState.Mutex.Lock()
+	gp.Volume = level
+	gp.PauseState.Mutex.Unlock()
+	gp.stateChanged()
+
+	if store := gp.bot.Settings; store != nil {
+		err := store.Update(gp.GuildID, func(o *GuildOverrides) { o.Volume = &level })
+		if err != nil {
+			// Still applied, just not kept across a restart
+			gp.log.Warn("Failed to save volume", "volume", level, "err", err)
+		}
+	}
+
+	gp.log.Info("Volume set", "volume", level)
+	return nil
+}
+
+// applyVolume scales little-endian s16 PCM in place. Levels above 100% are
+// soft clipped so loud passages saturate smoothly instead of wrapping.
+func applyVolume(pcm []byte, percent int) {
+	if percent == 100 {
+		return
+	}
+
+	gain := float64(percent) / 100
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := int16(uint16(pcm[i]) | uint16(pcm[i+1])<<8)
+		v := float64(sample) / 32768 * gain
+		if gain > 1 {
+			v = softClip(v)
+		}
+
+		out := int16(math.Max(-32768, math.Min(32767, v*32768)))
+		pcm[i] = byte(out)
+		pcm[i+1] = byte(uint16(out) >> 8)
+	}
+}
+
+// softClip passes samples below the knee through untouched and compresses
+// everything above it with tanh so the result never exceeds full scale
+func softClip(v float64) float64 {
+	abs := math.Abs(v)
+	if abs <= softClipKnee {
+		return v
+	}
+	headroom := 1 - softClipKnee
+	clipped := softClipKnee + headroom*math.Tanh((abs-softClipKnee)/headroom)
+	return math.Copysign(clipped, v)
+}
+
+// volumeSlash handles /volume <0-200>
+func (gp *GuildPlayer) volumeSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		respondMessage(s, i, fmt.Sprintf("Volume is %d%%.", gp.volume()))
+		return nil
+	}
+
+	level := int(options[0].IntValue())
+	if err := gp.SetVolume(level); err != nil {
+		respondMessage(s, i, fmt.Sprintf("Error: %v", err))
+		return err
+	}
+	respondMessage(s, i, fmt.Sprintf("Volume set to %d%%.", level))
+
+	if gp.CurrentSongMessageID != "" && gp.CurrentSongChannelID != "" {
+		gp.updateNowPlayingEmbed(s)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// voteskip.go
+package musicbot
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// minVoteSkipPercent and maxVoteSkipPercent bound the /settings vote_skip_percent option
+var (
+	minVoteSkipPercent = 1.0
+	maxVoteSkipPercent = 100.0
+)
+
+// VoteResult is the state of the vote to skip the current song after a vote
+type VoteResult struct {
+	Votes   int
+	Needed  int
+	Skipped bool
+}
+
+// Message describes the vote for the user who cast it
+func (r VoteResult) Message() string {
+	if r.Skipped {
+		return fmt.Sprintf("Vote passed (%d/%d), skipping the current song.", r.Votes, r.Needed)
+	}
+	return fmt.Sprintf("Voted to skip (%d/%d votes).", r.Votes, r.Needed)
+}
+
+// VoteSkip counts userID's vote to skip the current song, and skips it once the
+// votes exceed the guild's share of the people listening
+func (gp *GuildPlayer) VoteSkip(userID string) (VoteResult, error) {
+	gp.QueueMutex.Lock()
+	if gp.CurrentSong == nil {
+		gp.QueueMutex.Unlock()
+		return VoteResult{}, ErrNothingPlaying
+	}
+	listeners := gp.listeners()
+	if !listeners[userID] {
+		gp.QueueMutex.Unlock()
+		return VoteResult{}, ErrNotListening
+	}
+	if gp.skipVotes == nil {
+		gp.skipVotes = make(map[string]bool)
+	}
+	gp.skipVotes[userID] = true
+	res := gp.voteProgress(listeners)
+	gp.QueueMutex.Unlock()
+
+	gp.log.Info("Skip vote", "user_id", userID, "votes", res.Votes, "needed", res.Needed)
+	if res.Votes >= res.Needed {
+		res.Skipped = true
+		return res, gp.Skip()
+	}
+	return res, nil
+}
+
+// voteProgress counts the votes of people still listening; the caller must hold QueueMutex
+func (gp *GuildPlayer) voteProgress(listeners map[string]bool) VoteResult {
+	var res VoteResult
+	for userID := range gp.skipVotes {
+		if listeners[userID] {
+			res.Votes++
+		}
+	}
+
+	// More than the configured share of listeners must vote, but never more than all of them
+	percent := gp.bot.guildConfig(gp.GuildID).VoteSkipPercent
+	res.Needed = min(len(listeners)*percent/100+1, max(len(listeners), 1))
+	return res
+}
+
+// skipVoteProgress returns the votes to skip the current song, with Votes 0 when there are none
+func (gp *GuildPlayer) skipVoteProgress() VoteResult {
+	gp.QueueMutex.Lock()
+	defer gp.QueueMutex.Unlock()
+	if len(gp.skipVotes) == 0 {
+		return VoteResult{}
+	}
+	return gp.voteProgress(gp.listeners())
+}
+
+// resetSkipVotes drops every vote; the caller must hold QueueMutex
+func (gp *GuildPlayer) resetSkipVotes() {
+	gp.skipVotes = nil
+}
+
+// listeners returns the people, not bots, in the bot's voice channel
+func (gp *GuildPlayer) listeners() map[string]bool {
+	listeners := make(map[string]bool)
+	channelID := gp.voiceChannelID()
+	if channelID == "" {
+		return listeners
+	}
+	guild, err := gp.Session.State.Guild(gp.GuildID)
+	if err != nil {
+		return listeners
+	}
+
+	gp.Session.State.RLock()
+	defer gp.Session.State.RUnlock()
+	for _, vs := range guild.VoiceStates {
+		if vs.ChannelID == channelID && !gp.isBot(guild, vs) {
+			listeners[vs.UserID] = true
+		}
+	}
+	return listeners
+}
+
+// skipOrVote skips the current song for members who may control it, and
+// counts a vote for everyone else
+func (gp *GuildPlayer) skipOrVote(i *discordgo.InteractionCreate) (string, error) {
+	if gp.memberMayControl(i, gp.CurrentOwners()) {
+		if err := gp.Skip(); err != nil {
+			return "", err
+		}
+		return "Skipped current track. Moving to the next...", nil
+	}
+
+	res, err := gp.VoteSkip(interactionUserID(i))
+	if err != nil {
+		return "", err
+	}
+	return res.Message(), nil
+}
+
+// skipButton handles the Skip button under the Now Playing embed
+func (gp *GuildPlayer) skipButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	msg, err := gp.skipOrVote(i)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	respondEphemeral(s, i, msg)
+	gp.RefreshEmbed()
+}
+
+// dropSkipVote drops the vote of someone who left the bot's voice channel,
+// which may push the rest over the threshold, and every vote when the bot itself left
+func (gp *GuildPlayer) dropSkipVote(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	if vsu.BeforeUpdate == nil || vsu.BeforeUpdate.ChannelID == vsu.ChannelID {
+		return
+	}
+
+	gp.QueueMutex.Lock()
+	if len(gp.skipVotes) == 0 {
+		gp.QueueMutex.Unlock()
+		return
+	}
+	if s.State.User != nil && vsu.UserID == s.State.User.ID {
+		gp.resetSkipVotes()
+		gp.QueueMutex.Unlock()
+		return
+	}
+	delete(gp.skipVotes, vsu.UserID)
+	res := gp.voteProgress(gp.listeners())
+	passed := gp.CurrentSong != nil && res.Votes > 0 && res.Votes >= res.Needed
+	gp.QueueMutex.Unlock()
+
+	if passed {
+		gp.log.Info("Skip vote passed after a listener left", "votes", res.Votes, "needed", res.Needed)
+		_ = gp.Skip()
+	}
+	gp.RefreshEmbed()
+}
@@ -0,0 +1,279 @@
+// ytdlp.go
+package musicbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Reasons yt-dlp refuses a video, matched with errors.Is on a *YTDLPError
+var (
+	ErrVideoUnavailable = errors.New("video is unavailable")
+	ErrVideoPrivate     = errors.New("video is private")
+	ErrGeoBlocked       = errors.New("video is not available in this region")
+)
+
+// YTDLPError is returned when a yt-dlp invocation fails. Kind is one of the
+// Err* sentinels when the failure reason is recognised, otherwise nil.
+type YTDLPError struct {
+	Kind   error
+	Input  string
+	Stderr string
+	Err    error
+}
+
+func (e *YTDLPError) Error() string {
+	if e.Kind != nil {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("yt-dlp error: %v\n%s", e.Err, e.Stderr)
+}
+
+func (e *YTDLPError) Unwrap() error {
+	if e.Kind != nil {
+		return e.Kind
+	}
+	return e.Err
+}
+
+// classifyYTDLPError maps yt-dlp's stderr to a known failure reason. Only yt-dlp's
+// own phrases for a missing video match, not e.g. "Requested format is not available".
+func classifyYTDLPError(stderr string) error {
+	msg := strings.ToLower(stderr)
+	switch {
+	case strings.Contains(msg, "private video"), strings.Contains(msg, "video is private"):
+		return ErrVideoPrivate
+	case strings.Contains(msg, "not available in your country"),
+		strings.Contains(msg, "geo restriction"),
+		strings.Contains(msg, "geo-restricted"),
+		strings.Contains(msg, "blocked it in your country"):
+		return ErrGeoBlocked
+	case strings.Contains(msg, "video unavailable"),
+		strings.Contains(msg, "this video is not available"),
+		strings.Contains(msg, "this video has been removed"):
+		return ErrVideoUnavailable
+	}
+	return nil
+}
+
+// ytdlpChapter is a chapter marker from yt-dlp's info JSON
+type ytdlpChapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
+// ytdlpInfo is the subset of yt-dlp's --dump-json / -J output the bot uses.
+// For playlists (Type "playlist") only Title and Entries are meaningful.
+type ytdlpInfo struct {
+	Type        string            `json:"_type"`
+	ID          string            `json:"id"`
+	Title       string            `json:"title"`
+	URL         string            `json:"url"`
+	WebpageURL  string            `json:"webpage_url"`
+	Thumbnail   string            `json:"thumbnail"`
+	Duration    float64           `json:"duration"`
+	Uploader    string            `json:"uploader"`
+	Channel     string            `json:"channel"`
+	UploadDate  string            `json:"upload_date"`
+	IsLive      bool              `json:"is_live"`
+	Extractor   string            `json:"extractor_key"`
+	ACodec      string            `json:"acodec"`
+	ABR         float64           `json:"abr"`
+	HTTPHeaders map[string]string `json:"http_headers"`
+	Chapters    []ytdlpChapter    `json:"chapters"`
+	Thumbnails  []struct {
+		URL string `json:"url"`
+	} `json:"thumbnails"`
+	Entries []*ytdlpInfo `json:"entries"`
+}
+
+// ytdlpStreamURLMaxAge is how long a signed stream URL from yt-dlp is trusted
+const ytdlpStreamURLMaxAge = time.Hour
+
+// ytdlpResolver handles anything yt-dlp supports: site URLs, playlists and ytsearch queries
+type ytdlpResolver struct {
+	format string // -f selector
+}
+
+func (ytdlpResolver) Name() string { return "ytdlp" }
+
+func (ytdlpResolver) Match(input string) bool {
+	return isURL(input) || strings.HasPrefix(input, "ytsearch") || strings.HasPrefix(input, "scsearch")
+}
+
+func (r ytdlpResolver) Resolve(ctx context.Context, input string) ([]*Song, error) {
+	songs, _, err := fetchSongsInfo(ctx, input, r.format)
+	return songs, err
+}
+
+func (r ytdlpResolver) ResolveSong(ctx context.Context, input string) (*Song, error) {
+	return fetchSongInfo(ctx, input, r.format)
+}
+
+func (ytdlpResolver) StreamURLMaxAge() time.Duration { return ytdlpStreamURLMaxAge }
+
+// fetchSongInfo resolves a single video, ignoring any playlist the URL belongs to
+func fetchSongInfo(ctx context.Context, url, format string) (song *Song, err error) {
+	start := time.Now()
+	defer func() { observeResolve("song", start, err) }()
+
+	info, err := runYTDLPJSON(ctx, url, "-f", format, "--no-playlist", "-J")
+	if err != nil {
+		return nil, err
+	}
+
+	song, err = info.song(url)
+	if err != nil {
+		return nil, err
+	}
+
+	logFrom(ctx).Debug("Resolved song", "song_url", url, "song", song.Name, "uploader", song.Uploader,
+		"duration", song.Duration, "live", song.IsLive, "codec", song.Codec, "bitrate", song.Bitrate)
+	return song, nil
+}
+
+// fetchSongsInfo resolves url in one yt-dlp call. A single video comes back fully
+// resolved; a playlist comes back as flat entries without stream URLs, which
+// the registry resolves just before playback.
+func fetchSongsInfo(ctx context.Context, url, format string) (songs []*Song, isPlaylist bool, err error) {
+	start := time.Now()
+	defer func() { observeResolve("playlist", start, err) }()
+
+	info, err := runYTDLPJSON(ctx, url, "-f", format, "--flat-playlist", "-J")
+	if err != nil {
+		return nil, false, err
+	}
+
+	if info.Type != "playlist" {
+		song, err := info.song(url)
+		if err != nil {
+			return nil, false, err
+		}
+		return []*Song{song}, false, nil
+	}
+
+	for _, entry := range info.Entries {
+		if entry == nil {
+			continue
+		}
+		if song := entry.flatEntrySong(); song != nil {
+			songs = append(songs, song)
+		}
+	}
+	logFrom(ctx).Info("Resolved playlist", "song_url", url, "playlist", info.Title, "entries", len(songs))
+	return songs, true, nil
+}
+
+// runYTDLPJSON runs yt-dlp with args and decodes its single JSON document
+func runYTDLPJSON(ctx context.Context, input string, args ...string) (*ytdlpInfo, error) {
+	cmd := exec.CommandContext(ctx, "yt-dlp", append(args, input)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	logFrom(ctx).Debug("Running yt-dlp", "args", strings.Join(cmd.Args[1:], " "))
+	if err := cmd.Run(); err != nil {
+		// Error() shows users only the recognised reason, so the raw stderr is kept here
+		kind := classifyYTDLPError(stderr.String())
+		logFrom(ctx).Warn("yt-dlp command failed", "input", input, "kind", kind, "err", err, "stderr", stderr.String())
+		return nil, &YTDLPError{
+			Kind:   kind,
+			Input:  input,
+			Stderr: stderr.String(),
+			Err:    err,
+		}
+	}
+
+	var info ytdlpInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		return nil, fmt.Errorf("could not parse yt-dlp output: %v", err)
+	}
+	return &info, nil
+}
+
+// song converts resolved video info into a Song; originalURL is what the user asked for
+func (info *ytdlpInfo) song(originalURL string) (*Song, error) {
+	if info.URL == "" {
+		return nil, fmt.Errorf("yt-dlp returned no stream URL for %s", originalURL)
+	}
+
+	thumbnail := info.Thumbnail
+	if thumbnail == "" && len(info.Thumbnails) > 0 {
+		thumbnail = info.Thumbnails[len(info.Thumbnails)-1].URL
+	}
+	if !strings.HasPrefix(thumbnail, "http") {
+		slog.Debug("Invalid thumbnail URL from yt-dlp", "thumbnail", thumbnail)
+		thumbnail = defaultThumbnail
+	}
+
+	uploader := info.Uploader
+	if uploader == "" {
+		uploader = info.Channel
+	}
+
+	// Live streams report no meaningful duration
+	durationSeconds := int(info.Duration)
+	if info.IsLive {
+		durationSeconds = 0
+	}
+
+	chapters := make([]Chapter, 0, len(info.Chapters))
+	for _, c := range info.Chapters {
+		chapters = append(chapters, Chapter{Title: c.Title, Start: c.StartTime, End: c.EndTime})
+	}
+
+	return &Song{
+		Name:            info.Title,
+		StreamURL:       info.URL,
+		Duration:        formatDuration(durationSeconds),
+		DurationSeconds: durationSeconds,
+		Thumbnail:       thumbnail,
+		OriginalURL:     originalURL,
+		ResolvedAt:      time.Now(),
+		WebpageURL:      info.WebpageURL,
+		Uploader:        uploader,
+		UploadDate:      info.UploadDate,
+		IsLive:          info.IsLive,
+		Chapters:        chapters,
+		Extractor:       info.Extractor,
+		Codec:           info.ACodec,
+		Bitrate:         info.ABR,
+		HTTPHeaders:     info.HTTPHeaders,
+	}, nil
+}
+
+// flatEntrySong converts a --flat-playlist entry into a Song whose stream URL is resolved later
+func (info *ytdlpInfo) flatEntrySong() *Song {
+	pageURL := info.WebpageURL
+	if pageURL == "" {
+		pageURL = info.URL
+	}
+	if pageURL == "" {
+		return nil
+	}
+
+	song := &Song{
+		Name:            info.Title,
+		Duration:        formatDuration(int(info.Duration)),
+		DurationSeconds: int(info.Duration),
+		Thumbnail:       defaultThumbnail,
+		OriginalURL:     pageURL,
+		WebpageURL:      pageURL,
+		Uploader:        info.Uploader,
+	}
+	if n := len(info.Thumbnails); n > 0 {
+		song.Thumbnail = info.Thumbnails[n-1].URL
+	}
+	if song.Name == "" {
+		song.Name = pageURL
+	}
+	return song
+}